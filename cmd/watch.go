@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"github.com/gweebg/ipwatcher/internal/cli"
 	"github.com/gweebg/ipwatcher/internal/config"
 	"github.com/gweebg/ipwatcher/internal/database"
 	"github.com/gweebg/ipwatcher/internal/utils"
 	"github.com/gweebg/ipwatcher/internal/watcher"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
@@ -59,12 +64,41 @@ func main() {
 	config.Init(configFlags)
 	watcher.InitLogger()
 
-	database.ConnectDatabase()
-	db := database.GetDatabase()
+	// the records are kept on the database, or in memory for ephemeral runs
+	var store database.Store
+	if config.GetConfig().Get("database").(*config.Database).Memory {
+		store = database.NewMemoryStore()
+	} else {
+		database.ConnectDatabase()
 
-	err := db.AutoMigrate(&database.AddressEntry{})
-	utils.Check(err, "could not run database AutoMigrate")
+		// the schema is migrated on startup, unless managed by the command
+		if !cli.ManagesSchema(flag.Args()) {
+			applied, err := database.Migrate()
+			utils.Check(err, "could not migrate the database: %v", err)
 
-	w := watcher.NewWatcher()
-	w.Watch()
+			for _, migration := range applied {
+				log.Printf("applied database migration %d: %v\n", migration.Version, migration.Name)
+			}
+		}
+
+		store = database.NewGormStore(database.GetDatabase())
+	}
+
+	// the remaining arguments name a command to run instead of watching
+	if flag.NArg() > 0 {
+		err := cli.Run(flag.Args(), *version, store)
+		utils.Check(store.Close(), "could not close the database")
+		utils.Check(err, "")
+		return
+	}
+
+	// SIGINT and SIGTERM cancel the root context, stopping the watcher gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	w := watcher.NewWatcher(store)
+	w.Watch(ctx)
+
+	err := store.Close()
+	utils.Check(err, "could not close the database")
 }
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gweebg/ipwatcher/internal/database"
+	"github.com/gweebg/ipwatcher/internal/watcher"
+)
+
+const baselineUsage = "usage: baseline set <address> | baseline reset"
+
+// Baseline runs the 'baseline' command, which either seeds the baseline address
+// ('baseline set <address>') or resets it ('baseline reset') for the version, keeping
+// the recorded history.
+func Baseline(args []string, version string, store database.Store) error {
+
+	if len(args) == 0 {
+		return errors.New(baselineUsage)
+	}
+
+	switch args[0] {
+
+	case "set":
+		if len(args) != 2 {
+			return errors.New(baselineUsage)
+		}
+
+		entry, err := watcher.SeedBaseline(store, version, args[1])
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("baseline for %v set to %v\n", version, entry.Address)
+
+	case "reset":
+		if len(args) != 1 {
+			return errors.New(baselineUsage)
+		}
+
+		if _, err := watcher.ResetBaseline(store, version); err != nil {
+			return err
+		}
+
+		fmt.Printf("baseline for %v reset, the next check is handled as a first run\n", version)
+
+	default:
+		return errors.New(baselineUsage)
+	}
+
+	return nil
+}
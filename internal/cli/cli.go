@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/gweebg/ipwatcher/internal/database"
+)
+
+// Run runs the command named by the first element of args, with the remaining
+// arguments. version is the IP protocol version set by the 'version' flag, and
+// store holds the records the commands operate on.
+func Run(args []string, version string, store database.Store) error {
+
+	if len(args) == 0 {
+		return fmt.Errorf("no command given")
+	}
+
+	switch args[0] {
+	case "baseline":
+		return Baseline(args[1:], version, store)
+	case "db":
+		return Database(args[1:], store)
+	case "status":
+		return Status(args[1:], version, store)
+	case "history":
+		return History(args[1:], version, store)
+	case "uptime":
+		return Uptime(args[1:], version, store)
+	case "stats":
+		return Stats(args[1:], version, store)
+	case "predict":
+		return Predict(args[1:], version, store)
+	case "outcomes":
+		return Outcomes(args[1:], version, store)
+	default:
+		return fmt.Errorf("unknown command '%v'", args[0])
+	}
+}
+
+// ManagesSchema reports whether the command named by args manages the database schema
+// itself, in which case the pending migrations must not be applied beforehand.
+func ManagesSchema(args []string) bool {
+	return len(args) >= 2 && args[0] == "db" && (args[1] == "migrate" || args[1] == "status")
+}
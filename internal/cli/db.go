@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/database"
+	"github.com/gweebg/ipwatcher/internal/watcher"
+)
+
+const dbUsage = "usage: db migrate | db status | db prune | db vacuum"
+
+// Database runs the 'db' command, which either applies the pending schema migrations
+// ('db migrate'), lists the applied and pending ones ('db status'), removes the records
+// past their retention ('db prune') or reclaims the space they left ('db vacuum').
+func Database(args []string, store database.Store) error {
+
+	if len(args) != 1 {
+		return errors.New(dbUsage)
+	}
+
+	if database.GetDatabase() == nil {
+		return fmt.Errorf("the records are kept in memory ('database.memory'), there is no database to manage")
+	}
+
+	switch args[0] {
+
+	case "migrate":
+		applied, err := database.Migrate()
+		for _, migration := range applied {
+			fmt.Printf("applied %d: %v\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("schema is at version %d\n", database.LatestSchemaVersion())
+
+	case "status":
+		applied, err := database.SchemaStatus()
+		if err != nil {
+			return err
+		}
+
+		for _, version := range applied {
+			fmt.Printf("applied %d: %v (%v)\n", version.Version, version.Name, version.AppliedAt.Format(time.RFC3339))
+		}
+
+		for _, migration := range database.PendingMigrations(applied) {
+			fmt.Printf("pending %d: %v\n", migration.Version, migration.Name)
+		}
+
+	case "prune":
+		pruner := watcher.NewPruner(store, nil)
+
+		pruned, err := pruner.Prune()
+		if err != nil {
+			return err
+		}
+
+		action := "deleted"
+		if pruned.Summarized {
+			action = "summarized"
+		}
+		fmt.Printf("deleted %d address records, %v %d observations, deleted %d handled events\n",
+			pruned.Entries, action, pruned.Observations, pruned.Outcomes)
+
+	case "vacuum":
+		if err := store.Vacuum(); err != nil {
+			return err
+		}
+
+		fmt.Println("database vacuumed")
+
+	default:
+		return errors.New(dbUsage)
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/database"
+	"github.com/gweebg/ipwatcher/internal/watcher"
+)
+
+// History runs the 'history' command, which either exports the address records
+// ('history export'), imports them ('history import'), or lists the recorded checks.
+func History(args []string, version string, store database.Store) error {
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "export":
+			return Export(args[1:], version, store)
+		case "import":
+			return Import(args[1:], store)
+		}
+	}
+
+	return Checks(args, version, store)
+}
+
+// Export runs the 'history export' command, writing the address records of a version
+// to a file or to the standard output
+func Export(args []string, version string, store database.Store) error {
+
+	flags := flag.NewFlagSet("history export", flag.ContinueOnError)
+	format := flags.String("format", "", "output format, 'csv' | 'json' | 'jsonl', defaults to the output extension or 'csv'")
+	since := flags.String("since", "", "only export the records created since, e.g. '24h', '7d' or an RFC 3339 timestamp")
+	output := flags.String("output", "", "path of the output file, defaults to the standard output")
+	flags.StringVar(&version, "version", version, "version of the exported records, 'v4' | 'v6'")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	from, err := watcher.ParseSince(*since, time.Now())
+	if err != nil {
+		return err
+	}
+
+	stored, err := store.Entries(version, from)
+	if err != nil {
+		return err
+	}
+
+	// the reset markers are not addresses, only the baseline of the watcher
+	entries := make([]database.AddressEntry, 0, len(stored))
+	for _, entry := range stored {
+		if !entry.IsReset() {
+			entries = append(entries, entry)
+		}
+	}
+
+	var writer io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	if err = database.WriteEntries(writer, formatOf(*format, *output), entries); err != nil {
+		return err
+	}
+
+	if *output != "" {
+		fmt.Printf("exported %d %v records to %v\n", len(entries), version, *output)
+	}
+
+	return nil
+}
+
+// Import runs the 'history import' command, reading address records exported by
+// 'history export' from a file, or from the standard input if '-', skipping the
+// records that already exist.
+func Import(args []string, store database.Store) error {
+
+	flags := flag.NewFlagSet("history import", flag.ContinueOnError)
+	format := flags.String("format", "", "input format, 'csv' | 'json' | 'jsonl', defaults to the input extension or 'csv'")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: history import [--format csv|json|jsonl] <file|->")
+	}
+	input := flags.Arg(0)
+
+	var reader io.Reader = os.Stdin
+	if input != "-" {
+		file, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	entries, err := database.ReadEntries(reader, formatOf(*format, input))
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedAt < entries[j].CreatedAt
+	})
+
+	// the records already stored, or read before, are skipped
+	existing := map[string]map[string]bool{}
+
+	var imported, skipped int
+	for _, entry := range entries {
+
+		if existing[entry.Version] == nil {
+			stored, err := store.Entries(entry.Version, time.Time{})
+			if err != nil {
+				return err
+			}
+
+			existing[entry.Version] = map[string]bool{}
+			for _, record := range stored {
+				existing[entry.Version][entryKey(record)] = true
+			}
+		}
+
+		key := entryKey(entry)
+		if existing[entry.Version][key] {
+			skipped++
+			continue
+		}
+
+		if _, err = store.CreateEntry(entry); err != nil {
+			return err
+		}
+
+		existing[entry.Version][key] = true
+		imported++
+	}
+
+	fmt.Printf("imported %d records, skipped %d duplicates\n", imported, skipped)
+	return nil
+}
+
+// entryKey identifies an address record regardless of its ID, to detect duplicates
+func entryKey(entry database.AddressEntry) string {
+	return strings.Join([]string{
+		fmt.Sprint(entry.CreatedAt), entry.Address, entry.PreviousAddress, entry.Addresses,
+	}, "|")
+}
+
+// formatOf returns format if set, or else the format matching the extension of path, 'csv' by default
+func formatOf(format string, path string) string {
+
+	if format != "" {
+		return format
+	}
+
+	extension := strings.TrimPrefix(filepath.Ext(path), ".")
+	for _, known := range database.ExportFormats {
+		if extension == known {
+			return extension
+		}
+	}
+
+	return "csv"
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/database"
+	"github.com/gweebg/ipwatcher/internal/watcher"
+)
+
+// Checks runs the 'history' command without a subcommand, listing the recorded checks
+// of the version, newest first
+func Checks(args []string, version string, store database.Store) error {
+
+	flags := flag.NewFlagSet("history", flag.ContinueOnError)
+	since := flags.String("since", "", "only list the checks made since, e.g. '24h', '7d' or an RFC 3339 timestamp")
+	limit := flags.Int("limit", 50, "maximum number of checks listed, 0 lists them all")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	from, err := watcher.ParseSince(*since, time.Now())
+	if err != nil {
+		return err
+	}
+
+	history, err := store.Observations(version, from, *limit)
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "TIMESTAMP\tADDRESS\tLATENCY\tSOURCE\tERROR")
+
+	for _, observation := range history {
+		fmt.Fprintf(writer, "%s\t%s\t%dms\t%s\t%s\n",
+			observation.Timestamp.Format(time.RFC3339), observation.Address, observation.Latency,
+			observation.Source, observation.Error)
+	}
+
+	return writer.Flush()
+}
+
+// Uptime runs the 'uptime' command, printing the checks made, and failed, for the version
+func Uptime(args []string, version string, store database.Store) error {
+
+	flags := flag.NewFlagSet("uptime", flag.ContinueOnError)
+	since := flags.String("since", "24h", "period covered, e.g. '24h', '7d' or an RFC 3339 timestamp")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	from, err := watcher.ParseSince(*since, time.Now())
+	if err != nil {
+		return err
+	}
+
+	uptime, err := store.Uptime(version, from)
+	if err != nil {
+		return err
+	}
+
+	if uptime.Checks == 0 {
+		fmt.Printf("no checks recorded for %v since %v\n", version, from.Format(time.RFC3339))
+		return nil
+	}
+
+	fmt.Printf("checks:  %d (%d failed)\n", uptime.Checks, uptime.Failed)
+	fmt.Printf("uptime:  %.2f%%\n", uptime.Ratio*100)
+	fmt.Printf("first:   %v\n", uptime.First.Format(time.RFC3339))
+	fmt.Printf("last:    %v\n", uptime.Last.Format(time.RFC3339))
+
+	return nil
+}
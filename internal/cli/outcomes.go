@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/database"
+	"github.com/gweebg/ipwatcher/internal/watcher"
+)
+
+const outcomeUsage = "usage: outcomes show <id>"
+
+// Outcomes runs the 'outcomes' command, which either shows a handled event with the
+// output of its actions ('outcomes show <id>'), or lists the handled events of the
+// version, newest first, with how many of their actions and notifications succeeded.
+func Outcomes(args []string, version string, store database.Store) error {
+
+	if len(args) > 0 && args[0] == "show" {
+		return Outcome(args[1:], store)
+	}
+
+	flags := flag.NewFlagSet("outcomes", flag.ContinueOnError)
+	since := flags.String("since", "", "only list the events handled since, e.g. '24h', '7d' or an RFC 3339 timestamp")
+	limit := flags.Int("limit", 50, "maximum number of events listed, 0 lists them all")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	from, err := watcher.ParseSince(*since, time.Now())
+	if err != nil {
+		return err
+	}
+
+	outcomes, err := store.Outcomes(version, from, *limit)
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tTIMESTAMP\tEVENT\tADDRESS\tACTIONS\tNOTIFICATIONS")
+
+	for _, outcome := range outcomes {
+		actions, notifications := outcome.Succeeded()
+		fmt.Fprintf(writer, "%d\t%s\t%s\t%s\t%d/%d ok\t%d/%d sent\n",
+			outcome.ID, outcome.Timestamp.Format(time.RFC3339), outcome.Type, outcome.Current,
+			actions, len(outcome.Actions), notifications, len(outcome.Notifications))
+	}
+
+	return writer.Flush()
+}
+
+// Outcome runs the 'outcomes show <id>' command, printing the handled event with the
+// actions run, along with their output, and the notifications attempted on it
+func Outcome(args []string, store database.Store) error {
+
+	if len(args) != 1 {
+		return errors.New(outcomeUsage)
+	}
+
+	id, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("'%v' is not a valid id", args[0])
+	}
+
+	outcome, err := store.Outcome(id)
+	if err != nil {
+		return err
+	}
+
+	if outcome == nil {
+		return fmt.Errorf("no handled event with id %d", id)
+	}
+
+	fmt.Printf("event:     %v (%v)\n", outcome.Type, outcome.Version)
+	fmt.Printf("at:        %v\n", outcome.Timestamp.Format(time.RFC3339))
+	if outcome.Current != "" {
+		fmt.Printf("address:   %v\n", outcome.Current)
+	}
+	if outcome.Previous != "" {
+		fmt.Printf("previous:  %v\n", outcome.Previous)
+	}
+	if outcome.Error != "" {
+		fmt.Printf("error:     %v\n", outcome.Error)
+	}
+
+	for _, run := range outcome.Actions {
+
+		status := fmt.Sprintf("exit code %d", run.ExitCode)
+		if run.TimedOut {
+			status += ", timed out"
+		}
+		if run.Error != "" {
+			status += ", " + run.Error
+		}
+
+		fmt.Printf("\naction:    %v\n", run.Command)
+		fmt.Printf("ran:       %v for %v (%v)\n", run.Start.Format(time.RFC3339), run.End.Sub(run.Start).Round(time.Millisecond), status)
+		printOutput("stdout", run.Stdout)
+		printOutput("stderr", run.Stderr)
+	}
+
+	if len(outcome.Notifications) > 0 {
+		fmt.Println()
+	}
+
+	for _, attempt := range outcome.Notifications {
+
+		status := "sent"
+		if !attempt.Success {
+			status = "failed: " + attempt.Error
+		}
+
+		fmt.Printf("notified:  %v via %v at %v, %v\n", attempt.Recipient, attempt.Channel, attempt.Timestamp.Format(time.RFC3339), status)
+	}
+
+	return nil
+}
+
+// printOutput prints the captured output of an action, indented, if there is any
+func printOutput(name string, output string) {
+
+	output = strings.TrimRight(output, "\n")
+	if output == "" {
+		return
+	}
+
+	fmt.Printf("%v:\n", name)
+	for _, line := range strings.Split(output, "\n") {
+		fmt.Printf("    %v\n", line)
+	}
+}
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/database"
+	"github.com/gweebg/ipwatcher/internal/watcher"
+)
+
+// statsWeeks is the number of latest weeks listed by the 'stats' command
+const statsWeeks = 8
+
+// Stats runs the 'stats' command, printing the statistics of the address changes of the version
+func Stats(args []string, version string, store database.Store) error {
+
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	since := flags.String("since", "", "only account for the changes since, e.g. '24h', '7d' or an RFC 3339 timestamp")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	from, err := watcher.ParseSince(*since, now)
+	if err != nil {
+		return err
+	}
+
+	entries, err := store.Entries(version, from)
+	if err != nil {
+		return err
+	}
+
+	stats := watcher.ComputeStats(version, entries, now)
+	if stats.Records == 0 {
+		fmt.Printf("no addresses recorded for %v since %v\n", version, from.Format(time.RFC3339))
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(writer, "current address:\t%v (for %v)\n", stats.Current, roundDuration(stats.CurrentAge))
+	fmt.Fprintf(writer, "recorded since:\t%v\n", stats.From.Format(time.RFC3339))
+	fmt.Fprintf(writer, "changes:\t%d (%.2f per day)\n", stats.Changes, stats.MeanChangesPerDay)
+	fmt.Fprintf(writer, "lease duration:\tmean %v, max %v\n", roundDuration(stats.MeanLease), roundDuration(stats.MaxLease))
+
+	if stable := stats.LongestStable; stable != nil {
+		until := stable.To.Format(time.RFC3339)
+		if stable.Current {
+			until = "now"
+		}
+		fmt.Fprintf(writer, "longest stable:\t%v, %v from %v until %v\n",
+			roundDuration(stable.Duration), stable.Address, stable.From.Format(time.RFC3339), until)
+	}
+
+	if err = writer.Flush(); err != nil {
+		return err
+	}
+
+	if stats.Changes == 0 {
+		return nil
+	}
+
+	fmt.Println("\nchanges per week:")
+	weeks := make([]string, 0, len(stats.ChangesPerWeek))
+	for week := range stats.ChangesPerWeek {
+		weeks = append(weeks, week)
+	}
+	sort.Strings(weeks)
+	if len(weeks) > statsWeeks {
+		weeks = weeks[len(weeks)-statsWeeks:]
+	}
+	for _, week := range weeks {
+		fmt.Printf("  %v  %3d\n", week, stats.ChangesPerWeek[week])
+	}
+
+	fmt.Println("\nleases per address:")
+	writer = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "  ADDRESS\tLEASES\tMEAN\tMAX")
+	for _, lease := range stats.Leases {
+		fmt.Fprintf(writer, "  %v\t%d\t%v\t%v\n", lease.Address, lease.Leases, roundDuration(lease.Mean), roundDuration(lease.Max))
+	}
+	if err = writer.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println("\nchanges by hour of the day:")
+	busiest := 0
+	for _, changes := range stats.HourOfDay {
+		busiest = max(busiest, changes)
+	}
+	for hour, changes := range stats.HourOfDay {
+		bar := strings.Repeat("#", changes*40/busiest)
+		fmt.Printf("  %02d:00  %3d  %v\n", hour, changes, bar)
+	}
+
+	return nil
+}
+
+// Predict runs the 'predict' command, printing the window in which the next change of
+// the version is expected
+func Predict(args []string, version string, store database.Store) error {
+
+	if len(args) != 0 {
+		return fmt.Errorf("usage: predict")
+	}
+
+	now := time.Now()
+
+	prediction, err := watcher.PredictFrom(store, version, now)
+	if err != nil {
+		return err
+	}
+
+	if prediction == nil {
+		fmt.Printf("the %v address changes are not periodic, no change can be predicted\n", version)
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(writer, "expected at:\t%v (in %v)\n", prediction.Expected.Format(time.RFC3339), roundDuration(prediction.Expected.Sub(now)))
+	fmt.Fprintf(writer, "window:\t%v to %v\n", prediction.Start.Format(time.RFC3339), prediction.End.Format(time.RFC3339))
+	fmt.Fprintf(writer, "period:\t%v (deviation %v, from %d intervals)\n",
+		roundDuration(prediction.Period), prediction.Deviation.Round(time.Second), prediction.Samples)
+	fmt.Fprintf(writer, "latest change:\t%v\n", prediction.LastChange.Format(time.RFC3339))
+
+	return writer.Flush()
+}
+
+// roundDuration rounds d to the minute, or to the second if shorter than a minute
+func roundDuration(d time.Duration) time.Duration {
+	if d < time.Minute {
+		return d.Round(time.Second)
+	}
+	return d.Round(time.Minute)
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/database"
+)
+
+// Status runs the 'status' command, which prints the runtime state persisted by the
+// watcher of the version: its latest check and successful check, and the ongoing outage.
+func Status(args []string, version string, store database.Store) error {
+
+	if len(args) != 0 {
+		return errors.New("usage: status")
+	}
+
+	state, err := store.LoadState(version)
+	if err != nil {
+		return err
+	}
+
+	if state == nil {
+		fmt.Printf("the %v watcher has not run yet\n", version)
+		return nil
+	}
+
+	fmt.Printf("last check:   %v\n", formatMoment(state.LastCheck))
+	fmt.Printf("last success: %v\n", formatMoment(state.LastSuccess))
+
+	if state.ConsecutiveErrors > 0 {
+		fmt.Printf("failing:      since %v, %d failed checks\n", formatMoment(state.FailingSince), state.ConsecutiveErrors)
+	}
+
+	// stopped gracefully, rather than running, killed or crashed
+	if !state.StoppedAt.IsZero() && !state.StoppedAt.Before(state.LastCheck) {
+		fmt.Printf("stopped:      %v\n", formatMoment(state.StoppedAt))
+	}
+
+	return nil
+}
+
+// formatMoment formats t for the output of the commands, "never" if zero
+func formatMoment(t time.Time) string {
+
+	if t.IsZero() {
+		return "never"
+	}
+
+	return t.Format(time.RFC3339)
+}
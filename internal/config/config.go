@@ -32,6 +32,10 @@ func load(userFlags map[string]interface{}) {
 		config.Set("flags."+key, rv.Elem().Interface())
 	} // append the flags set by the user to the configuration object
 
+	parsedDatabase, err := getDatabase()
+	utils.Check(err, "")
+	config.Set("database", parsedDatabase)
+
 	parsedSources, err := getSources()
 	utils.Check(err, "")
 	config.Set("sources", parsedSources)
@@ -40,6 +44,46 @@ func load(userFlags map[string]interface{}) {
 	utils.Check(err, "")
 	config.Set("watcher.events", parsedEvents)
 
+	parsedNat, err := getNat()
+	utils.Check(err, "")
+	config.Set("watcher.nat", parsedNat)
+
+	parsedV6, err := getV6()
+	utils.Check(err, "")
+	config.Set("watcher.v6", parsedV6)
+
+	parsedSet, err := getAddressSet()
+	utils.Check(err, "")
+	config.Set("watcher.set", parsedSet)
+
+	parsedConfirm, err := getConfirm()
+	utils.Check(err, "")
+	config.Set("watcher.confirm", parsedConfirm)
+
+	parsedFlapping, err := getFlapping()
+	utils.Check(err, "")
+	config.Set("watcher.flapping", parsedFlapping)
+
+	parsedPolling, err := getPolling()
+	utils.Check(err, "")
+	config.Set("watcher.polling", parsedPolling)
+
+	parsedSchedule, err := getSchedule()
+	utils.Check(err, "")
+	config.Set("watcher.schedule", parsedSchedule)
+
+	parsedQuietHours, err := getQuietHours()
+	utils.Check(err, "")
+	config.Set("watcher.quiet_hours", parsedQuietHours)
+
+	parsedObservations, err := getObservations()
+	utils.Check(err, "")
+	config.Set("watcher.observations", parsedObservations)
+
+	parsedPrediction, err := getPrediction()
+	utils.Check(err, "")
+	config.Set("watcher.prediction", parsedPrediction)
+
 }
 
 func GetConfig() *viper.Viper {
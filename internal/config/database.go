@@ -0,0 +1,121 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/robfig/cron/v3"
+)
+
+// legacyDatabasePath is where the database used to be created, relative to the working directory
+const legacyDatabasePath = "watcher.db"
+
+// Database holds the settings of the database, defined under 'database'
+type Database struct {
+	// Path of the database file, defaults to defaultDatabasePath
+	Path string `mapstructure:"path"`
+	// Wal enables the write-ahead log journal mode, enabled by default
+	Wal bool `mapstructure:"wal"`
+	// BusyTimeout is the time, in milliseconds, waited for a locked database before failing
+	BusyTimeout int `mapstructure:"busy_timeout"`
+	// Memory keeps the records in memory instead, nothing outlives the run
+	Memory bool `mapstructure:"memory"`
+
+	// Retention defines how long the records are kept for
+	Retention Retention `mapstructure:"retention"`
+	// PruneInterval is the interval, in seconds, between the prunes of the expired records
+	PruneInterval int `mapstructure:"prune_interval"`
+	// Vacuum is the cron expression at which the database is vacuumed, empty disables it
+	Vacuum string `mapstructure:"vacuum"`
+}
+
+// Retention holds the retention policies of the records, defined under 'database.retention'
+type Retention struct {
+	// Observations is the number of days the observations are kept for, 0 keeps them forever
+	Observations int `mapstructure:"observations"`
+	// Summaries downsamples the expired observations into daily summaries, instead of
+	// discarding them
+	Summaries bool `mapstructure:"summaries"`
+	// Changes is the number of latest changes kept per version, along with the baselines they
+	// were made from, 0 keeps them all
+	Changes int `mapstructure:"changes"`
+	// Outcomes is the number of days the handled events, and their outcomes, are kept for,
+	// 0 keeps them forever
+	Outcomes int `mapstructure:"outcomes"`
+}
+
+func getDatabase() (*Database, error) {
+
+	if config == nil {
+		return nil, errors.New("the 'database' field can only be acquired after config initialization")
+	}
+
+	database := Database{
+		Wal:         true,
+		BusyTimeout: 5000,
+		Retention: Retention{
+			Observations: 30,
+			Summaries:    true,
+			Outcomes:     90,
+		},
+		PruneInterval: 3600,
+	}
+
+	err := config.UnmarshalKey("database", &database)
+	if err != nil {
+		return nil, err
+	}
+
+	if database.BusyTimeout < 0 {
+		return nil, errors.New("the 'busy_timeout' field cannot be negative")
+	}
+
+	if database.Retention.Observations < 0 || database.Retention.Changes < 0 || database.Retention.Outcomes < 0 {
+		return nil, errors.New("the 'retention.observations', 'retention.changes' and 'retention.outcomes' fields cannot be negative")
+	}
+
+	if database.PruneInterval <= 0 {
+		return nil, errors.New("the 'prune_interval' field must be greater than 0")
+	}
+
+	if database.Vacuum != "" {
+		if _, err := cron.ParseStandard(database.Vacuum); err != nil {
+			return nil, errors.New("invalid cron expression '" + database.Vacuum + "' in 'database.vacuum': " + err.Error())
+		}
+	}
+
+	if database.Path == "" {
+		database.Path, err = defaultDatabasePath()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &database, nil
+}
+
+// defaultDatabasePath returns '/var/lib/ipwatcher/watcher.db' when running as root, and
+// '$XDG_DATA_HOME/ipwatcher/watcher.db' (or '~/.local/share/ipwatcher/watcher.db') otherwise.
+// A 'watcher.db' on the working directory, where it used to be created, is kept in use.
+func defaultDatabasePath() (string, error) {
+
+	if _, err := os.Stat(legacyDatabasePath); err == nil {
+		return legacyDatabasePath, nil
+	}
+
+	if os.Geteuid() == 0 {
+		return filepath.Join("/var/lib", "ipwatcher", "watcher.db"), nil
+	}
+
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", errors.New("cannot find a default location for the database, set 'database.path'")
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+
+	return filepath.Join(dataHome, "ipwatcher", "watcher.db"), nil
+}
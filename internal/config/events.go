@@ -10,17 +10,35 @@ import (
 	"time"
 )
 
+// ExecuteAction is an action run on an event. Its 'bin', 'args', 'env' and 'workdir' are
+// templates, rendered with the ActionData of the event before running it.
 type ExecuteAction struct {
 	Type string `mapstructure:"type"`
 	Bin  string `mapstructure:"bin"`
 	Args string `mapstructure:"args"`
 	TTL  int    `mapstructure:"ttl"`
+	// Env are the additional environment variables of the action, as 'NAME=value'
+	Env []string `mapstructure:"env"`
+	// Workdir is the working directory of the action, the one of the watcher if empty
+	Workdir string `mapstructure:"workdir"`
+	// Stdin writes the event, JSON encoded, to the standard input of the action
+	Stdin bool `mapstructure:"stdin"`
+
+	// arguments are the rendered arguments, nil until the action is rendered
+	arguments []string
 }
 
 func (s ExecuteAction) Command(ttl time.Duration) (*exec.Cmd, context.Context, context.CancelFunc) {
 
+	args := s.arguments
+	if args == nil {
+		args = strings.Fields(s.Args)
+	}
+
 	if s.TTL < 0 {
-		return exec.Command(s.Bin, strings.Split(s.Args, " ")...), nil, nil
+		cmd := exec.Command(s.Bin, args...)
+		cmd.Dir = s.Workdir
+		return cmd, nil, nil
 	}
 
 	if s.TTL > 0 { // ttl > 0, use this
@@ -28,16 +46,70 @@ func (s ExecuteAction) Command(ttl time.Duration) (*exec.Cmd, context.Context, c
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), ttl)
-	cmd := exec.CommandContext(ctx, s.Bin, strings.Split(s.Args, " ")...)
+	cmd := exec.CommandContext(ctx, s.Bin, args...)
+	cmd.Dir = s.Workdir
 	return cmd, ctx, cancel
 }
 
+// Render returns the action with its 'bin', 'args', 'env' and 'workdir' templates rendered with data.
+// Each argument is rendered on its own, so that a value with spaces stays a single argument,
+// and the ones rendered empty are dropped.
+func (s ExecuteAction) Render(data ActionData) (ExecuteAction, error) {
+
+	rendered := s
+	var err error
+
+	if rendered.Bin, err = renderTemplate("bin", s.Bin, data); err != nil {
+		return s, err
+	}
+
+	rendered.arguments = []string{}
+	for _, arg := range splitArgs(s.Args) {
+
+		value, err := renderTemplate("args", arg, data)
+		if err != nil {
+			return s, err
+		}
+
+		if value != "" {
+			rendered.arguments = append(rendered.arguments, value)
+		}
+	}
+	rendered.Args = strings.Join(rendered.arguments, " ")
+
+	if rendered.Workdir, err = renderTemplate("workdir", s.Workdir, data); err != nil {
+		return s, err
+	}
+
+	rendered.Env = make([]string, len(s.Env))
+	for i, variable := range s.Env {
+		if rendered.Env[i], err = renderTemplate("env", variable, data); err != nil {
+			return s, err
+		}
+	}
+
+	return rendered, nil
+}
+
+// Validate checks the type of the action, and its templates by rendering them with a sample
+// event. The executable is only looked up if 'bin' is not a template.
 func (s ExecuteAction) Validate() error {
 
 	if strings.ToLower(strings.TrimSpace(s.Type)) != "execute" {
 		return errors.New("as for now, 'execute' is the only action type possible")
 	}
-	if !s.CheckInstalled() {
+
+	if _, err := s.Render(sampleActionData()); err != nil {
+		return fmt.Errorf("invalid template on action '%v': %w", s, err)
+	}
+
+	for _, variable := range s.Env {
+		if name, _, found := strings.Cut(variable, "="); !found || name == "" {
+			return fmt.Errorf("invalid environment variable '%v' on action '%v', must be 'NAME=value'", variable, s)
+		}
+	}
+
+	if !strings.Contains(s.Bin, "{{") && !s.CheckInstalled() {
 		return errors.New(
 			"could not find executable for '" + s.Bin + "', you can check if it's installed by running 'which " + s.Bin + "'",
 		)
@@ -60,6 +132,8 @@ type EventHandler struct {
 	Actions []ExecuteAction `mapstructure:"actions"`
 }
 
+// Validate validates the actions of the handler, templates included, so that errors
+// are caught on config load rather than on the first event
 func (e EventHandler) Validate() error {
 	for _, e := range e.Actions {
 		err := e.Validate()
@@ -77,6 +151,22 @@ type Events struct {
 	OnMatch *EventHandler `mapstructure:"on_match"`
 	// OnError event handler, information about what to do when an error occurs
 	OnError *EventHandler `mapstructure:"on_error"`
+	// OnNat event handler, information about what to do when the WAN side is behind a NAT or CGNAT
+	OnNat *EventHandler `mapstructure:"on_nat"`
+	// OnFlapping event handler, information about what to do when the address starts flapping
+	OnFlapping *EventHandler `mapstructure:"on_flapping"`
+	// OnStart event handler, information about what to do when the watcher starts
+	OnStart *EventHandler `mapstructure:"on_start"`
+	// OnStop event handler, information about what to do when the watcher stops
+	OnStop *EventHandler `mapstructure:"on_stop"`
+	// OnRecover event handler, information about what to do when checks succeed again after failing
+	OnRecover *EventHandler `mapstructure:"on_recover"`
+	// OnFirstSeen event handler, information about what to do when there is no previous address
+	OnFirstSeen *EventHandler `mapstructure:"on_first_seen"`
+	// OnDowntime event handler, information about what to do when starting after a previous run
+	OnDowntime *EventHandler `mapstructure:"on_downtime"`
+	// OnPreChange event handler, information about what to do ahead of a predicted change
+	OnPreChange *EventHandler `mapstructure:"on_pre_change"`
 }
 
 func getEvents() (*Events, error) {
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"errors"
+)
+
+// Nat holds the settings used to compare the WAN side address with the
+// externally observed address, defined under 'watcher.nat'.
+type Nat struct {
+	// Interface is the name of the local interface holding the WAN address (e.g. 'ppp0')
+	Interface string `mapstructure:"interface"`
+	// Router is a source reporting the router WAN address, takes precedence over Interface
+	Router *Source `mapstructure:"router"`
+}
+
+func getNat() (*Nat, error) {
+
+	if config == nil {
+		return nil, errors.New("the 'watcher.nat' field can only be acquired after config initialization")
+	}
+
+	if !config.IsSet("watcher.nat") {
+		return nil, nil // nat detection is optional
+	}
+
+	var nat Nat
+
+	err := config.UnmarshalKey("watcher.nat", &nat)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateNat(nat)
+	if err != nil {
+		return nil, err
+	}
+
+	return &nat, nil
+}
+
+func validateNat(nat Nat) error {
+
+	if nat.Interface == "" && nat.Router == nil {
+		return errors.New("the 'watcher.nat' field must have either 'interface' or 'router' specified")
+	}
+
+	if nat.Router != nil {
+		return validateSources([]Source{*nat.Router})
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"errors"
+)
+
+// Observations holds the settings used to record every check, defined under
+// 'watcher.observations'. How long they are kept for is defined under 'database.retention'.
+type Observations struct {
+	// Enabled toggles the recording of the checks, enabled by default
+	Enabled bool `mapstructure:"enabled"`
+	// Sample records one in every Sample successful checks, failed checks are always recorded
+	Sample int `mapstructure:"sample"`
+}
+
+func getObservations() (*Observations, error) {
+
+	if config == nil {
+		return nil, errors.New("the 'watcher.observations' field can only be acquired after config initialization")
+	}
+
+	observations := Observations{
+		Enabled: true,
+		Sample:  1,
+	}
+
+	err := config.UnmarshalKey("watcher.observations", &observations)
+	if err != nil {
+		return nil, err
+	}
+
+	if observations.Sample < 1 {
+		return nil, errors.New("the 'sample' field must be greater or equal to 1")
+	}
+
+	return &observations, nil
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"errors"
+)
+
+// Polling holds the settings used to adapt the interval between checks, defined
+// under 'watcher.polling'. The base interval is 'watcher.timeout'.
+type Polling struct {
+	// BackoffFactor multiplies the interval after each consecutive failed check
+	BackoffFactor float64 `mapstructure:"backoff_factor"`
+	// BackoffMax caps the interval, in seconds, while backing off
+	BackoffMax int `mapstructure:"backoff_max"`
+
+	// FastInterval is the interval, in seconds, used right after a change or a network signal
+	FastInterval int `mapstructure:"fast_interval"`
+	// FastDuration is how long, in seconds, the fast interval is kept for
+	FastDuration int `mapstructure:"fast_duration"`
+
+	// Jitter is the fraction of the interval randomly added or removed from it, between 0 and 1
+	Jitter float64 `mapstructure:"jitter"`
+}
+
+func getPolling() (*Polling, error) {
+
+	if config == nil {
+		return nil, errors.New("the 'watcher.polling' field can only be acquired after config initialization")
+	}
+
+	polling := Polling{
+		BackoffFactor: 2,
+		BackoffMax:    3600,
+	}
+
+	err := config.UnmarshalKey("watcher.polling", &polling)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validatePolling(polling)
+	if err != nil {
+		return nil, err
+	}
+
+	return &polling, nil
+}
+
+func validatePolling(polling Polling) error {
+
+	if polling.BackoffFactor < 1 {
+		return errors.New("the 'backoff_factor' field must be greater or equal to 1")
+	}
+
+	if polling.BackoffMax <= 0 {
+		return errors.New("the 'backoff_max' field must be greater than 0")
+	}
+
+	if polling.FastInterval < 0 || polling.FastDuration < 0 {
+		return errors.New("the 'fast_interval' and 'fast_duration' fields cannot be negative")
+	}
+
+	if polling.Jitter < 0 || polling.Jitter > 1 {
+		return errors.New("the 'jitter' field must be between 0 and 1")
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"errors"
+)
+
+// Prediction holds the settings used to predict the next address change from the
+// history of changes, defined under 'watcher.prediction'.
+type Prediction struct {
+	// Enabled tightens the polling around the predicted changes, and raises on_pre_change
+	Enabled bool `mapstructure:"enabled"`
+
+	// History is the number of latest changes the pattern is learnt from
+	History int `mapstructure:"history"`
+	// MinChanges is the minimum number of regular intervals between changes to predict from
+	MinChanges int `mapstructure:"min_changes"`
+	// MaxDeviation is the maximum deviation of the regular intervals, as a fraction of their
+	// median, for the changes to be considered periodic
+	MaxDeviation float64 `mapstructure:"max_deviation"`
+
+	// Interval is the interval, in seconds, between checks during the predicted window
+	Interval int `mapstructure:"interval"`
+	// Lead is the time, in seconds, before the predicted window the polling is tightened
+	Lead int `mapstructure:"lead"`
+	// Warning is the time, in seconds, before the predicted window on_pre_change is raised,
+	// 0 disables it
+	Warning int `mapstructure:"warning"`
+}
+
+func getPrediction() (*Prediction, error) {
+
+	if config == nil {
+		return nil, errors.New("the 'watcher.prediction' field can only be acquired after config initialization")
+	}
+
+	prediction := Prediction{
+		History:      30,
+		MinChanges:   3,
+		MaxDeviation: 0.1,
+		Interval:     10,
+		Lead:         300,
+	}
+
+	err := config.UnmarshalKey("watcher.prediction", &prediction)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validatePrediction(prediction)
+	if err != nil {
+		return nil, err
+	}
+
+	return &prediction, nil
+}
+
+func validatePrediction(prediction Prediction) error {
+
+	if prediction.MinChanges < 2 || prediction.History <= prediction.MinChanges {
+		return errors.New("the 'min_changes' field must be at least 2, and lower than 'history'")
+	}
+
+	if prediction.MaxDeviation <= 0 || prediction.MaxDeviation > 1 {
+		return errors.New("the 'max_deviation' field must be between 0 and 1")
+	}
+
+	if prediction.Interval <= 0 {
+		return errors.New("the 'interval' field must be greater than 0")
+	}
+
+	if prediction.Lead < 0 || prediction.Warning < 0 {
+		return errors.New("the 'lead' and 'warning' fields cannot be negative")
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"errors"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// QuietHours is a daily window, defined under 'watcher.quiet_hours', during which
+// checks still run but notifications are held until the window ends.
+type QuietHours struct {
+	// Start of the window, in the 'HH:MM' format
+	Start string `mapstructure:"start"`
+	// End of the window, in the 'HH:MM' format, may be earlier than Start to span midnight
+	End string `mapstructure:"end"`
+}
+
+// Times returns the start and end of the window as offsets from midnight
+func (q QuietHours) Times() (time.Duration, time.Duration, error) {
+
+	start, err := time.Parse("15:04", q.Start)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	end, err := time.Parse("15:04", q.End)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	midnight := time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)
+	return start.Sub(midnight), end.Sub(midnight), nil
+}
+
+func getSchedule() ([]string, error) {
+
+	if config == nil {
+		return nil, errors.New("the 'watcher.schedule' field can only be acquired after config initialization")
+	}
+
+	schedule := config.GetStringSlice("watcher.schedule")
+	for _, expression := range schedule {
+		if _, err := cron.ParseStandard(expression); err != nil {
+			return nil, errors.New("invalid cron expression '" + expression + "' in 'watcher.schedule': " + err.Error())
+		}
+	}
+
+	if len(schedule) == 0 && config.GetInt("watcher.timeout") <= 0 {
+		return nil, errors.New("the 'watcher.timeout' field must be greater than 0 when 'watcher.schedule' is not specified")
+	}
+
+	return schedule, nil
+}
+
+func getQuietHours() ([]QuietHours, error) {
+
+	if config == nil {
+		return nil, errors.New("the 'watcher.quiet_hours' field can only be acquired after config initialization")
+	}
+
+	var quietHours []QuietHours
+
+	err := config.UnmarshalKey("watcher.quiet_hours", &quietHours)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, window := range quietHours {
+		if _, _, err := window.Times(); err != nil {
+			return nil, errors.New("the 'start' and 'end' fields of 'watcher.quiet_hours' must be in the 'HH:MM' format")
+		}
+	}
+
+	return quietHours, nil
+}
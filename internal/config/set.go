@@ -0,0 +1,36 @@
+package config
+
+import (
+	"errors"
+)
+
+// AddressSet holds the settings of the set mode, defined under 'watcher.set'. In set
+// mode every global address is tracked instead of the single source reported one.
+type AddressSet struct {
+	// Interfaces are the local interfaces to collect addresses from, all if empty
+	Interfaces []string `mapstructure:"interfaces"`
+}
+
+func getAddressSet() (*AddressSet, error) {
+
+	if config == nil {
+		return nil, errors.New("the 'watcher.set' field can only be acquired after config initialization")
+	}
+
+	if !config.IsSet("watcher.set") {
+		return nil, nil // set mode is optional
+	}
+
+	var set AddressSet
+
+	err := config.UnmarshalKey("watcher.set", &set)
+	if err != nil {
+		return nil, err
+	}
+
+	if v6, ok := config.Get("watcher.v6").(*V6); ok && v6.PrefixLength > 0 {
+		return nil, errors.New("the 'watcher.set' and 'watcher.v6.prefix_length' fields cannot be used together")
+	}
+
+	return &set, nil
+}
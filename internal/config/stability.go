@@ -0,0 +1,67 @@
+package config
+
+import (
+	"errors"
+)
+
+// Confirm holds the settings used to debounce address changes, defined under 'watcher.confirm'.
+// A new address is only committed once observed for Checks consecutive checks or for Seconds.
+type Confirm struct {
+	// Checks is the number of consecutive checks the new address must be observed on
+	Checks int `mapstructure:"checks"`
+	// Seconds is the time the new address must be observed for
+	Seconds int `mapstructure:"seconds"`
+}
+
+// Flapping holds the settings used to detect a bouncing address, defined under 'watcher.flapping'.
+// The address is flapping when it changes at least Changes times within Window seconds.
+type Flapping struct {
+	// Changes is the number of observed changes within Window to consider the address flapping, 0 disables it
+	Changes int `mapstructure:"changes"`
+	// Window is the sliding window, in seconds, where changes are counted
+	Window int `mapstructure:"window"`
+}
+
+func getConfirm() (*Confirm, error) {
+
+	if config == nil {
+		return nil, errors.New("the 'watcher.confirm' field can only be acquired after config initialization")
+	}
+
+	var confirm Confirm
+
+	err := config.UnmarshalKey("watcher.confirm", &confirm)
+	if err != nil {
+		return nil, err
+	}
+
+	if confirm.Checks < 0 || confirm.Seconds < 0 {
+		return nil, errors.New("the 'checks' and 'seconds' fields of 'watcher.confirm' cannot be negative")
+	}
+
+	return &confirm, nil
+}
+
+func getFlapping() (*Flapping, error) {
+
+	if config == nil {
+		return nil, errors.New("the 'watcher.flapping' field can only be acquired after config initialization")
+	}
+
+	var flapping Flapping
+
+	err := config.UnmarshalKey("watcher.flapping", &flapping)
+	if err != nil {
+		return nil, err
+	}
+
+	if flapping.Changes < 0 || flapping.Window < 0 {
+		return nil, errors.New("the 'changes' and 'window' fields of 'watcher.flapping' cannot be negative")
+	}
+
+	if flapping.Changes > 0 && flapping.Window == 0 {
+		return nil, errors.New("the 'window' field must be specified when 'watcher.flapping.changes' is set")
+	}
+
+	return &flapping, nil
+}
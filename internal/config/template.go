@@ -0,0 +1,199 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"net/netip"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ActionData is the data the templates of an action ('bin', 'args', 'env' and 'workdir')
+// are rendered with, describing the event the action runs on
+type ActionData struct {
+	// Event is the event the action runs on, e.g. 'on_change'
+	Event string
+	// Version of the watched address (v4|v6)
+	Version string
+	// Current is the observed address, or the address set in set mode, comma separated without
+	// spaces, e.g. '203.0.113.7,203.0.113.8'
+	Current string
+	// Previous is the previously committed address, or address set, only set on on_change
+	Previous string
+	// Source is the url of the source the address was obtained from
+	Source string
+	// Error is the error that caused an on_error event, empty otherwise
+	Error string
+	// Prefix is the tracked IPv6 prefix in CIDR notation, only set on on_change when tracking prefixes
+	Prefix string
+	// Hosts are the LAN host addresses derived from the prefix by host name, only set on
+	// on_change when hosts are defined, e.g. '{{index .Hosts "nas.home.example.com"}}'
+	Hosts map[string]string
+	// Timestamp is the moment the event happened
+	Timestamp time.Time
+}
+
+// actionFuncs are the helper functions available to the templates of the actions
+var actionFuncs = template.FuncMap{
+	"prefix":  prefixOf,
+	"reverse": reverseName,
+	"split":   splitAddresses,
+	"join":    strings.Join,
+}
+
+// sampleActionData returns the data the templates are validated with, an on_change of
+// the watched version
+func sampleActionData() ActionData {
+
+	data := ActionData{
+		Event:     "on_change",
+		Version:   "v4",
+		Current:   "203.0.113.7",
+		Previous:  "203.0.113.8",
+		Source:    "https://api.ipify.org?format=json",
+		Timestamp: time.Now(),
+	}
+
+	if c := GetConfig(); c != nil && c.GetString("flags.version") == "v6" {
+		data.Version = "v6"
+		data.Current = "2001:db8:1234:5678::1"
+		data.Previous = "2001:db8:1234:5679::1"
+		data.Prefix = "2001:db8:1234:5678::/64"
+
+		if v6, err := getV6(); err == nil && len(v6.Hosts) > 0 {
+			data.Hosts = make(map[string]string, len(v6.Hosts))
+			for _, host := range v6.Hosts {
+				data.Hosts[host.Name] = data.Current
+			}
+		}
+	}
+
+	return data
+}
+
+// renderTemplate parses text as the template named name and renders it with data
+func renderTemplate(name string, text string, data ActionData) (string, error) {
+
+	tmpl, err := template.New(name).Funcs(actionFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", err
+	}
+
+	return rendered.String(), nil
+}
+
+// splitArgs splits the 'args' template of an action into the templates of each argument,
+// on the spaces outside of the actions, so that '--zone {{join (split .Current) " "}}' is
+// split into two arguments
+func splitArgs(args string) []string {
+
+	var fields []string
+	var field strings.Builder
+	inAction := false
+
+	for i := 0; i < len(args); i++ {
+		switch {
+		case strings.HasPrefix(args[i:], "{{"):
+			inAction = true
+			field.WriteString("{{")
+			i++
+		case inAction && strings.HasPrefix(args[i:], "}}"):
+			inAction = false
+			field.WriteString("}}")
+			i++
+		case !inAction && (args[i] == ' ' || args[i] == '\t'):
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteByte(args[i])
+		}
+	}
+
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+
+	return fields
+}
+
+// prefixOf returns the prefix of the given length of address in CIDR notation, e.g.
+// '{{.Current | prefix 56}}' renders '2001:db8:1234:5600::/56'. An empty address is left empty.
+func prefixOf(length int, address string) (string, error) {
+
+	if address == "" {
+		return "", nil
+	}
+
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return "", err
+	}
+
+	prefix, err := addr.Prefix(length)
+	if err != nil {
+		return "", err
+	}
+
+	return prefix.String(), nil
+}
+
+// reverseName returns the reverse DNS name of an address, e.g. '{{.Current | reverse}}' renders
+// '7.113.0.203.in-addr.arpa', or the reverse zone of a prefix, truncated to its whole octets
+// (v4) or nibbles (v6). An empty address is left empty.
+func reverseName(address string) (string, error) {
+
+	if address == "" {
+		return "", nil
+	}
+
+	var prefix netip.Prefix
+	var err error
+	if strings.Contains(address, "/") {
+		prefix, err = netip.ParsePrefix(address)
+	} else {
+		var addr netip.Addr
+		addr, err = netip.ParseAddr(address)
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+	if err != nil {
+		return "", err
+	}
+
+	addr := prefix.Masked().Addr()
+	octets := addr.AsSlice()
+
+	var labels []string
+	if addr.Is4() {
+		for i := prefix.Bits()/8 - 1; i >= 0; i-- {
+			labels = append(labels, fmt.Sprint(octets[i]))
+		}
+		return strings.Join(append(labels, "in-addr.arpa"), "."), nil
+	}
+
+	for i := prefix.Bits()/4 - 1; i >= 0; i-- {
+		nibble := octets[i/2] >> 4
+		if i%2 == 1 {
+			nibble = octets[i/2] & 0x0f
+		}
+		labels = append(labels, fmt.Sprintf("%x", nibble))
+	}
+	return strings.Join(append(labels, "ip6.arpa"), "."), nil
+}
+
+// splitAddresses splits the comma separated address set of set mode, e.g.
+// '{{join (split .Current) " "}}'. An empty set is split into no addresses.
+func splitAddresses(addresses string) []string {
+
+	if addresses == "" {
+		return nil
+	}
+	return strings.Split(addresses, ",")
+}
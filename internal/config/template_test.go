@@ -0,0 +1,126 @@
+package config
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestReverseName(t *testing.T) {
+
+	cases := []struct {
+		address  string
+		expected string
+	}{
+		{"", ""},
+		{"203.0.113.7", "7.113.0.203.in-addr.arpa"},
+		{"203.0.113.0/24", "113.0.203.in-addr.arpa"},
+		// truncated to the whole octets of the prefix
+		{"203.0.113.0/20", "0.203.in-addr.arpa"},
+		{"2001:db8::/32", "8.b.d.0.1.0.0.2.ip6.arpa"},
+		{"2001:db8:1234:5600::/56", "6.5.4.3.2.1.8.b.d.0.1.0.0.2.ip6.arpa"},
+		{"2001:db8::1", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.address, func(t *testing.T) {
+
+			name, err := reverseName(c.address)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != c.expected {
+				t.Fatalf("expected '%v', got '%v'", c.expected, name)
+			}
+		})
+	}
+
+	if _, err := reverseName("not an address"); err == nil {
+		t.Fatal("expected an invalid address to fail")
+	}
+}
+
+func TestPrefixOf(t *testing.T) {
+
+	prefix, err := prefixOf(56, "2001:db8:1234:5678::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefix != "2001:db8:1234:5600::/56" {
+		t.Fatalf("expected '2001:db8:1234:5600::/56', got '%v'", prefix)
+	}
+
+	if prefix, err = prefixOf(56, ""); err != nil || prefix != "" {
+		t.Fatalf("expected an empty address to be left empty, got '%v' (%v)", prefix, err)
+	}
+
+	if _, err = prefixOf(200, "2001:db8::1"); err == nil {
+		t.Fatal("expected an invalid length to fail")
+	}
+}
+
+func TestSplitAddresses(t *testing.T) {
+
+	if addresses := splitAddresses(""); addresses != nil {
+		t.Fatalf("expected an empty set to be split into no addresses, got %v", addresses)
+	}
+
+	addresses := splitAddresses("203.0.113.7,203.0.113.8")
+	if !slices.Equal(addresses, []string{"203.0.113.7", "203.0.113.8"}) {
+		t.Fatalf("expected the two addresses, got %v", addresses)
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+
+	data := ActionData{Event: "on_change", Current: "203.0.113.7,203.0.113.8"}
+
+	rendered, err := renderTemplate("args", `{{.Event}} {{join (split .Current) " "}}`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered != "on_change 203.0.113.7 203.0.113.8" {
+		t.Fatalf("unexpected rendered template '%v'", rendered)
+	}
+
+	if _, err = renderTemplate("args", "{{.Missing}}", data); err == nil {
+		t.Fatal("expected an unknown field to fail")
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+
+	args := splitArgs(`--address {{.Current}}  --hosts {{join (split .Current) " "}}`)
+	expected := []string{"--address", "{{.Current}}", "--hosts", `{{join (split .Current) " "}}`}
+	if !slices.Equal(args, expected) {
+		t.Fatalf("expected %q, got %q", expected, args)
+	}
+
+	if args = splitArgs(""); args != nil {
+		t.Fatalf("expected no arguments, got %q", args)
+	}
+}
+
+func TestRenderArgs(t *testing.T) {
+
+	action := ExecuteAction{
+		Type: "execute",
+		Bin:  "echo",
+		Args: `{{join (split .Current) " "}} {{.Previous}} {{index .Hosts "nas"}}`,
+	}
+	data := ActionData{Current: "203.0.113.7,203.0.113.8", Hosts: map[string]string{"nas": "2001:db8::1"}}
+
+	rendered, err := action.Render(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the empty previous address is dropped, rather than passed as an empty argument
+	cmd, _, cancel := rendered.Command(time.Minute)
+	defer cancel()
+
+	expected := []string{"echo", "203.0.113.7 203.0.113.8", "2001:db8::1"}
+	if !slices.Equal(cmd.Args, expected) {
+		t.Fatalf("expected the arguments %q, got %q", expected, cmd.Args)
+	}
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"errors"
+	"net/netip"
+)
+
+// Host is a LAN host whose IPv6 address is derived from the tracked prefix
+type Host struct {
+	// Name identifies the host, e.g. its DNS name
+	Name string `mapstructure:"name"`
+	// Suffix is the stable interface identifier of the host, e.g. '::1234:5678:9abc:def0'
+	Suffix string `mapstructure:"suffix"`
+}
+
+// V6 holds the IPv6 specific settings, defined under 'watcher.v6'
+type V6 struct {
+	// PrefixLength is the length of the delegated prefix to track, 0 tracks the full address
+	PrefixLength int `mapstructure:"prefix_length"`
+	// Hosts are the LAN hosts whose addresses are derived from the prefix on change
+	Hosts []Host `mapstructure:"hosts"`
+}
+
+func getV6() (*V6, error) {
+
+	if config == nil {
+		return nil, errors.New("the 'watcher.v6' field can only be acquired after config initialization")
+	}
+
+	var v6 V6
+
+	err := config.UnmarshalKey("watcher.v6", &v6)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateV6(v6)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v6, nil
+}
+
+func validateV6(v6 V6) error {
+
+	if v6.PrefixLength < 0 || v6.PrefixLength > 128 {
+		return errors.New("the 'prefix_length' field must be between 0 and 128")
+	}
+
+	if len(v6.Hosts) > 0 && v6.PrefixLength == 0 {
+		return errors.New("the 'hosts' field requires 'prefix_length' to be specified")
+	}
+
+	for _, host := range v6.Hosts {
+
+		if host.Name == "" {
+			return errors.New("the 'name' field must be specified for every host")
+		}
+
+		suffix, err := netip.ParseAddr(host.Suffix)
+		if err != nil || !suffix.Is6() || suffix.Is4In6() {
+			return errors.New("the 'suffix' field of host '" + host.Name + "' must be an IPv6 interface identifier, e.g. '::1'")
+		}
+	}
+
+	return nil
+}
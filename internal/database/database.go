@@ -1,17 +1,29 @@
 package database
 
 import (
+	"os"
+	"path/filepath"
+
+	"github.com/gweebg/ipwatcher/internal/config"
 	"github.com/gweebg/ipwatcher/internal/utils"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 var db *gorm.DB
 
+// ConnectDatabase opens the database at 'database.path', creating its directory if needed,
+// with the journal mode and busy timeout defined under 'database'. The SQLite driver is
+// chosen at build time, see open.
 func ConnectDatabase() {
 	var err error
 
-	db, err = gorm.Open(sqlite.Open("watcher.db"), &gorm.Config{})
+	c := config.GetConfig()
+	settings := c.Get("database").(*config.Database)
+
+	err = os.MkdirAll(filepath.Dir(settings.Path), 0o750)
+	utils.Check(err, "could not create the database directory: %v", err)
+
+	db, err = gorm.Open(open(settings.Path, settings.Wal, settings.BusyTimeout), &gorm.Config{})
 	utils.Check(err, "")
 }
 
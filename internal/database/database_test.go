@@ -0,0 +1,40 @@
+package database
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDatabase connects to an in-memory SQLite database, migrated, in place of the
+// database of the package for the duration of the test
+func newTestDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	database, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("could not open the database: %v", err)
+	}
+
+	// every connection to ':memory:' is a distinct database
+	sqlDB, err := database.DB()
+	if err != nil {
+		t.Fatalf("could not get the database connection: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	previous := db
+	db = database
+	t.Cleanup(func() { db = previous })
+
+	if _, err := Migrate(); err != nil {
+		t.Fatalf("could not migrate the database: %v", err)
+	}
+
+	return database
+}
@@ -0,0 +1,186 @@
+package database
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ExportFormats are the formats address records are exported to, and imported from
+var ExportFormats = []string{"csv", "json", "jsonl"}
+
+// exportColumns are the CSV columns of an ExportedEntry, in order
+var exportColumns = []string{"at", "version", "address", "previous_address", "prefix", "addresses", "added", "removed", "nat"}
+
+// ExportedEntry is the portable representation of an AddressEntry, without its ID
+type ExportedEntry struct {
+	// At is the moment the address update was detected
+	At time.Time `json:"at"`
+	// Version specifies the version of the address the record refers to
+	Version string `json:"version"`
+
+	Address         string `json:"address"`
+	PreviousAddress string `json:"previous_address"`
+	Prefix          string `json:"prefix,omitempty"`
+	Addresses       string `json:"addresses,omitempty"`
+	Added           string `json:"added,omitempty"`
+	Removed         string `json:"removed,omitempty"`
+	Nat             string `json:"nat,omitempty"`
+}
+
+// Export returns the portable representation of the record
+func (e AddressEntry) Export() ExportedEntry {
+	return ExportedEntry{
+		At:              time.Unix(int64(e.CreatedAt), 0).UTC(),
+		Version:         e.Version,
+		Address:         e.Address,
+		PreviousAddress: e.PreviousAddress,
+		Prefix:          e.Prefix,
+		Addresses:       e.Addresses,
+		Added:           e.Added,
+		Removed:         e.Removed,
+		Nat:             e.Nat,
+	}
+}
+
+// Entry returns the record the portable representation stands for, without an ID
+func (e ExportedEntry) Entry() AddressEntry {
+	return AddressEntry{
+		CreatedAt:       uint64(e.At.Unix()),
+		Version:         e.Version,
+		Address:         e.Address,
+		PreviousAddress: e.PreviousAddress,
+		Prefix:          e.Prefix,
+		Addresses:       e.Addresses,
+		Added:           e.Added,
+		Removed:         e.Removed,
+		Nat:             e.Nat,
+	}
+}
+
+// WriteEntries writes entries to w in the format, one of ExportFormats
+func WriteEntries(w io.Writer, format string, entries []AddressEntry) error {
+
+	exported := make([]ExportedEntry, 0, len(entries))
+	for _, entry := range entries {
+		exported = append(exported, entry.Export())
+	}
+
+	switch format {
+
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(exported)
+
+	case "jsonl":
+		encoder := json.NewEncoder(w)
+		for _, entry := range exported {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write(exportColumns); err != nil {
+			return err
+		}
+
+		for _, entry := range exported {
+			err := writer.Write([]string{
+				entry.At.Format(time.RFC3339), entry.Version, entry.Address, entry.PreviousAddress,
+				entry.Prefix, entry.Addresses, entry.Added, entry.Removed, entry.Nat,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		writer.Flush()
+		return writer.Error()
+	}
+
+	return unknownFormat(format)
+}
+
+// ReadEntries reads the records written by WriteEntries from r, in the format
+func ReadEntries(r io.Reader, format string) ([]AddressEntry, error) {
+
+	var exported []ExportedEntry
+
+	switch format {
+
+	case "json":
+		if err := json.NewDecoder(r).Decode(&exported); err != nil {
+			return nil, err
+		}
+
+	case "jsonl":
+		decoder := json.NewDecoder(r)
+		for {
+			var entry ExportedEntry
+			err := decoder.Decode(&entry)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("record %d: %w", len(exported)+1, err)
+			}
+			exported = append(exported, entry)
+		}
+
+	case "csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = len(exportColumns)
+
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(records) == 0 || strings.Join(records[0], ",") != strings.Join(exportColumns, ",") {
+			return nil, fmt.Errorf("expected the CSV header '%v'", strings.Join(exportColumns, ","))
+		}
+
+		for i, record := range records[1:] {
+
+			at, err := time.Parse(time.RFC3339, record[0])
+			if err != nil {
+				return nil, fmt.Errorf("record %d: %w", i+1, err)
+			}
+
+			exported = append(exported, ExportedEntry{
+				At: at, Version: record[1], Address: record[2], PreviousAddress: record[3],
+				Prefix: record[4], Addresses: record[5], Added: record[6], Removed: record[7], Nat: record[8],
+			})
+		}
+
+	default:
+		return nil, unknownFormat(format)
+	}
+
+	entries := make([]AddressEntry, 0, len(exported))
+	for i, entry := range exported {
+
+		if entry.Version != "v4" && entry.Version != "v6" {
+			return nil, fmt.Errorf("record %d: version must be either 'v4' or 'v6', not '%v'", i+1, entry.Version)
+		}
+
+		if entry.At.IsZero() || entry.Address == "" {
+			return nil, fmt.Errorf("record %d: missing the 'at' or 'address' fields", i+1)
+		}
+
+		entries = append(entries, entry.Entry())
+	}
+
+	return entries, nil
+}
+
+func unknownFormat(format string) error {
+	return fmt.Errorf("unknown format '%v', supports '%v'", format, strings.Join(ExportFormats, "' | '"))
+}
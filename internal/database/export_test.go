@@ -0,0 +1,74 @@
+package database
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+
+	snapshot := entryAt(1, "v4", "203.0.113.1", "203.0.113.1")
+	snapshot.Addresses = "203.0.113.1,203.0.113.2"
+	snapshot.Added = "203.0.113.2"
+	snapshot.Nat = "none"
+
+	prefixed := entryAt(2, "v6", "2001:db8::2", "2001:db8::1")
+	prefixed.Prefix = "2001:db8::/64"
+
+	entries := []AddressEntry{entryAt(0, "v4", "203.0.113.1", "203.0.113.1"), snapshot, prefixed}
+	for i := range entries {
+		entries[i].ID = uint64(i + 1)
+	}
+
+	for _, format := range ExportFormats {
+		t.Run(format, func(t *testing.T) {
+
+			var buffer bytes.Buffer
+			if err := WriteEntries(&buffer, format, entries); err != nil {
+				t.Fatal(err)
+			}
+
+			read, err := ReadEntries(&buffer, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(read) != len(entries) {
+				t.Fatalf("expected %d entries, got %d", len(entries), len(read))
+			}
+
+			for i, entry := range read {
+				expected := entries[i]
+				expected.ID = 0 // not exported
+				if entry != expected {
+					t.Fatalf("expected %+v, got %+v", expected, entry)
+				}
+			}
+		})
+	}
+}
+
+func TestReadEntriesErrors(t *testing.T) {
+
+	cases := []struct {
+		name   string
+		format string
+		input  string
+	}{
+		{"unknown format", "xml", ""},
+		{"invalid version", "jsonl", `{"at":"2024-03-10T12:00:00Z","version":"v5","address":"203.0.113.1"}`},
+		{"missing address", "jsonl", `{"at":"2024-03-10T12:00:00Z","version":"v4"}`},
+		{"missing timestamp", "json", `[{"version":"v4","address":"203.0.113.1"}]`},
+		{"wrong header", "csv", "at,version,address\n"},
+		{"invalid timestamp", "csv", strings.Join(exportColumns, ",") + "\nyesterday,v4,203.0.113.1,,,,,,\n"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := ReadEntries(strings.NewReader(c.input), c.format); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,440 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is the Store that keeps the records in memory, nothing outlives it
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	entries      []AddressEntry
+	states       map[string]RuntimeState
+	observations []Observation
+	summaries    map[string]ObservationSummary
+
+	records  []EventRecord
+	runs     []ActionRun
+	attempts []NotificationAttempt
+
+	// lastID is the ID of the latest created record, shared by every kind of record
+	lastID uint64
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		states:    map[string]RuntimeState{},
+		summaries: map[string]ObservationSummary{},
+	}
+}
+
+func (s *MemoryStore) CreateEntry(entry AddressEntry) (*AddressEntry, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// like the database, an ID set on the entry is kept, and must be unused
+	if entry.ID == 0 {
+		s.lastID++
+		entry.ID = s.lastID
+	} else {
+		for _, existing := range s.entries {
+			if existing.ID == entry.ID {
+				return nil, fmt.Errorf("an entry with the ID %d already exists", entry.ID)
+			}
+		}
+		s.lastID = max(s.lastID, entry.ID)
+	}
+
+	if entry.CreatedAt == 0 {
+		entry.CreatedAt = uint64(time.Now().Unix())
+	}
+
+	s.entries = append(s.entries, entry)
+	return &entry, nil
+}
+
+func (s *MemoryStore) Latest(version string) (*AddressEntry, error) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var latest *AddressEntry
+	for i := range s.entries {
+		entry := s.entries[i]
+		if entry.Version == version && (latest == nil || entry.CreatedAt >= latest.CreatedAt) {
+			latest = &entry
+		}
+	}
+
+	return latest, nil
+}
+
+func (s *MemoryStore) Entries(version string, since time.Time) ([]AddressEntry, error) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []AddressEntry
+	for _, entry := range s.entries {
+		if entry.Version != version {
+			continue
+		}
+		if !since.IsZero() && int64(entry.CreatedAt) < since.Unix() {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedAt < entries[j].CreatedAt
+	})
+
+	return entries, nil
+}
+
+func (s *MemoryStore) Changes(version string, limit int) ([]AddressEntry, error) {
+
+	entries, err := s.Entries(version, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []AddressEntry
+	for _, entry := range entries {
+		if entry.IsChange() {
+			changes = append(changes, entry)
+		}
+	}
+
+	if limit > 0 && len(changes) > limit {
+		changes = changes[len(changes)-limit:]
+	}
+
+	return changes, nil
+}
+
+func (s *MemoryStore) PruneEntries(keep int) (int64, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the entries of each version, newest first, like the database orders them
+	byVersion := map[string][]AddressEntry{}
+	for _, entry := range s.entries {
+		byVersion[entry.Version] = append(byVersion[entry.Version], entry)
+	}
+
+	expired := map[uint64]bool{}
+	for _, entries := range byVersion {
+
+		sort.SliceStable(entries, func(i, j int) bool {
+			if entries[i].CreatedAt != entries[j].CreatedAt {
+				return entries[i].CreatedAt > entries[j].CreatedAt
+			}
+			return entries[i].ID > entries[j].ID
+		})
+
+		for _, id := range expiredEntries(entries, keep) {
+			expired[id] = true
+		}
+	}
+
+	kept := s.entries[:0]
+	for _, entry := range s.entries {
+		if !expired[entry.ID] {
+			kept = append(kept, entry)
+		}
+	}
+
+	deleted := int64(len(s.entries) - len(kept))
+	s.entries = kept
+
+	return deleted, nil
+}
+
+func (s *MemoryStore) LoadState(version string) (*RuntimeState, error) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, ok := s.states[version]
+	if !ok {
+		return nil, nil
+	}
+
+	return &state, nil
+}
+
+func (s *MemoryStore) SaveState(state RuntimeState) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[state.Version] = state
+	return nil
+}
+
+func (s *MemoryStore) CreateObservation(observation Observation) (*Observation, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	observation.ID = s.lastID
+
+	s.observations = append(s.observations, observation)
+	return &observation, nil
+}
+
+func (s *MemoryStore) Observations(version string, since time.Time, limit int) ([]Observation, error) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var observations []Observation
+	for i := len(s.observations) - 1; i >= 0; i-- { // created in order, newest last
+
+		observation := s.observations[i]
+		if observation.Version != version || observation.Timestamp.Before(since) {
+			continue
+		}
+
+		observations = append(observations, observation)
+		if limit > 0 && len(observations) == limit {
+			break
+		}
+	}
+
+	return observations, nil
+}
+
+func (s *MemoryStore) Uptime(version string, since time.Time) (*Uptime, error) {
+
+	observations, err := s.Observations(version, since, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	uptime := Uptime{Version: version, Since: since}
+	for _, observation := range observations {
+
+		uptime.Checks++
+		if observation.Error != "" {
+			uptime.Failed++
+		}
+	}
+
+	if uptime.Checks > 0 {
+		uptime.First, uptime.Last = observations[len(observations)-1].Timestamp, observations[0].Timestamp
+		uptime.Ratio = float64(uptime.Checks-uptime.Failed) / float64(uptime.Checks)
+	}
+
+	summaries, err := s.Summaries(version, since)
+	if err != nil {
+		return nil, err
+	}
+
+	addSummaries(&uptime, summaries)
+	return &uptime, nil
+}
+
+func (s *MemoryStore) PruneObservations(before time.Time) (int64, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.observations[:0]
+	for _, observation := range s.observations {
+		if !observation.Timestamp.Before(before) {
+			kept = append(kept, observation)
+		}
+	}
+
+	deleted := int64(len(s.observations) - len(kept))
+	s.observations = kept
+
+	return deleted, nil
+}
+
+func (s *MemoryStore) SummarizeObservations(before time.Time) (int64, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired []Observation
+	kept := s.observations[:0]
+	for _, observation := range s.observations {
+		if observation.Timestamp.Before(before) {
+			expired = append(expired, observation)
+		} else {
+			kept = append(kept, observation)
+		}
+	}
+	s.observations = kept
+
+	for _, summary := range summarize(expired) {
+
+		key := summary.Version + "/" + summary.Day
+		if existing, ok := s.summaries[key]; ok {
+			summary = existing.merge(summary)
+		}
+		s.summaries[key] = summary
+	}
+
+	return int64(len(expired)), nil
+}
+
+func (s *MemoryStore) Summaries(version string, since time.Time) ([]ObservationSummary, error) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	day := since.UTC().Format(summaryDay)
+
+	var summaries []ObservationSummary
+	for _, summary := range s.summaries {
+		if summary.Version == version && summary.Day >= day {
+			summaries = append(summaries, summary)
+		}
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Day < summaries[j].Day
+	})
+
+	return summaries, nil
+}
+
+func (s *MemoryStore) CreateEventRecord(record EventRecord) (*EventRecord, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	record.ID = s.lastID
+
+	s.records = append(s.records, record)
+	return &record, nil
+}
+
+func (s *MemoryStore) CreateActionRun(run ActionRun) (*ActionRun, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	run.ID = s.lastID
+
+	s.runs = append(s.runs, run)
+	return &run, nil
+}
+
+func (s *MemoryStore) CreateNotificationAttempt(attempt NotificationAttempt) (*NotificationAttempt, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	attempt.ID = s.lastID
+
+	s.attempts = append(s.attempts, attempt)
+	return &attempt, nil
+}
+
+func (s *MemoryStore) Outcomes(version string, since time.Time, limit int) ([]EventOutcome, error) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []EventRecord
+	for i := len(s.records) - 1; i >= 0; i-- { // created in order, newest last
+
+		record := s.records[i]
+		if record.Version != version || record.Timestamp.Before(since) {
+			continue
+		}
+
+		records = append(records, record)
+		if limit > 0 && len(records) == limit {
+			break
+		}
+	}
+
+	return s.withOutcomes(records), nil
+}
+
+func (s *MemoryStore) Outcome(id uint64) (*EventOutcome, error) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, record := range s.records {
+		if record.ID == id {
+			outcome := s.withOutcomes([]EventRecord{record})[0]
+			return &outcome, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// withOutcomes groups the actions run and the notifications attempted on records
+func (s *MemoryStore) withOutcomes(records []EventRecord) []EventOutcome {
+
+	runs := append([]ActionRun{}, s.runs...)
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Start.Before(runs[j].Start)
+	})
+
+	return outcomesOf(records, runs, s.attempts)
+}
+
+func (s *MemoryStore) PruneOutcomes(before time.Time) (int64, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := map[uint64]bool{}
+	records := s.records[:0]
+	for _, record := range s.records {
+		if record.Timestamp.Before(before) {
+			expired[record.ID] = true
+		} else {
+			records = append(records, record)
+		}
+	}
+	s.records = records
+
+	runs := s.runs[:0]
+	for _, run := range s.runs {
+		if !expired[run.EventID] {
+			runs = append(runs, run)
+		}
+	}
+	s.runs = runs
+
+	attempts := s.attempts[:0]
+	for _, attempt := range s.attempts {
+		if !expired[attempt.EventID] {
+			attempts = append(attempts, attempt)
+		}
+	}
+	s.attempts = attempts
+
+	return int64(len(expired)), nil
+}
+
+func (s *MemoryStore) Vacuum() error {
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
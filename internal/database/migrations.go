@@ -0,0 +1,233 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SchemaVersion is the record of an applied migration, stored on the 'schema_version' table
+type SchemaVersion struct {
+	// Version is the version of the schema the migration leads to
+	Version int `gorm:"primaryKey" json:"version"`
+	// Name describes the migration
+	Name string `json:"name"`
+	// AppliedAt is the moment the migration was applied
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+func (SchemaVersion) TableName() string {
+	return "schema_version"
+}
+
+// Migration is a forward-only change of the schema. Migrations are applied in order, each
+// one in a transaction, and are never changed once released: a schema change is a new
+// migration appended to migrations.
+type Migration struct {
+	// Version is the version of the schema the migration leads to
+	Version int
+	// Name describes the migration
+	Name string
+
+	migrate func(tx *gorm.DB) error
+}
+
+// migrations are the schema migrations, ordered by version
+var migrations = []Migration{
+	{Version: 1, Name: "create address entries", migrate: createAddressEntries},
+	{Version: 2, Name: "repair address entries created by AutoMigrate", migrate: repairAddressEntries},
+	{Version: 3, Name: "create runtime states", migrate: createRuntimeStates},
+	{Version: 4, Name: "create observations", migrate: createObservations},
+	{Version: 5, Name: "create observation summaries", migrate: createObservationSummaries},
+	{Version: 6, Name: "create event outcomes", migrate: createEventOutcomes},
+}
+
+// Migrate applies the pending migrations, returning the applied ones
+func Migrate() ([]Migration, error) {
+
+	database := GetDatabase()
+
+	err := database.Exec(
+		"CREATE TABLE IF NOT EXISTS `schema_version` (`version` integer PRIMARY KEY,`name` text,`applied_at` datetime)",
+	).Error
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := SchemaStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, migration := range PendingMigrations(current) {
+
+		err = database.Transaction(func(tx *gorm.DB) error {
+
+			if err := migration.migrate(tx); err != nil {
+				return err
+			}
+
+			return tx.Create(&SchemaVersion{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+
+		if err != nil {
+			return applied, fmt.Errorf("migration %d (%v) failed: %w", migration.Version, migration.Name, err)
+		}
+
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+// SchemaStatus returns the applied migrations, oldest first
+func SchemaStatus() ([]SchemaVersion, error) {
+
+	database := GetDatabase()
+
+	// nothing was applied if the database was never migrated
+	if !database.Migrator().HasTable(&SchemaVersion{}) {
+		return nil, nil
+	}
+
+	var versions []SchemaVersion
+	if err := database.Order("version ASC").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+// PendingMigrations returns the migrations not yet applied, given the applied versions
+func PendingMigrations(applied []SchemaVersion) []Migration {
+
+	current := 0
+	if len(applied) > 0 {
+		current = applied[len(applied)-1].Version
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		if migration.Version > current {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending
+}
+
+// LatestSchemaVersion returns the version of the schema once every migration is applied
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+const addressEntriesColumns = "`id` integer PRIMARY KEY AUTOINCREMENT,`address` text,`previous_address` text," +
+	"`prefix` text,`addresses` text,`added` text,`removed` text,`nat` text,`version` text,`created_at` integer"
+
+func createAddressEntries(tx *gorm.DB) error {
+
+	statements := []string{
+		"CREATE TABLE IF NOT EXISTS `address_entries` (" + addressEntriesColumns + ")",
+		"CREATE INDEX IF NOT EXISTS `idx_address_entries_address` ON `address_entries`(`address`)",
+	}
+
+	return execAll(tx, statements)
+}
+
+// repairAddressEntries rebuilds the 'address_entries' tables created by AutoMigrate, when AddressEntry
+// embedded gorm.Model, dropping its 'updated_at' and 'deleted_at' columns and the soft deleted records.
+func repairAddressEntries(tx *gorm.DB) error {
+
+	migrator := tx.Migrator()
+	if !migrator.HasColumn("address_entries", "deleted_at") {
+		return nil
+	}
+
+	// copy the columns the table has, depending on the version that created it
+	var columns []string
+	for _, column := range []string{"id", "address", "previous_address", "prefix", "addresses", "added", "removed", "nat", "version", "created_at"} {
+		if migrator.HasColumn("address_entries", column) {
+			columns = append(columns, "`"+column+"`")
+		}
+	}
+	copied := strings.Join(columns, ",")
+
+	statements := []string{
+		"CREATE TABLE `address_entries_repaired` (" + addressEntriesColumns + ")",
+		"INSERT INTO `address_entries_repaired` (" + copied + ") SELECT " + copied + " FROM `address_entries` WHERE `deleted_at` IS NULL",
+		"DROP TABLE `address_entries`",
+		"ALTER TABLE `address_entries_repaired` RENAME TO `address_entries`",
+		"CREATE INDEX `idx_address_entries_address` ON `address_entries`(`address`)",
+	}
+
+	return execAll(tx, statements)
+}
+
+func createRuntimeStates(tx *gorm.DB) error {
+
+	statements := []string{
+		"CREATE TABLE IF NOT EXISTS `runtime_states` (`version` text,`consecutive_errors` integer,`failing_since` datetime," +
+			"`last_check` datetime,`last_success` datetime,`stopped_at` datetime,`source_health` text,`nat_status` text,PRIMARY KEY (`version`))",
+	}
+
+	return execAll(tx, statements)
+}
+
+func createObservations(tx *gorm.DB) error {
+
+	statements := []string{
+		"CREATE TABLE IF NOT EXISTS `observations` (`id` integer PRIMARY KEY AUTOINCREMENT,`timestamp` datetime,`version` text," +
+			"`source` text,`address` text,`error` text,`latency` integer)",
+		"CREATE INDEX IF NOT EXISTS `idx_observations_timestamp` ON `observations`(`timestamp`)",
+		"CREATE INDEX IF NOT EXISTS `idx_observations_version` ON `observations`(`version`)",
+	}
+
+	return execAll(tx, statements)
+}
+
+func createObservationSummaries(tx *gorm.DB) error {
+
+	statements := []string{
+		"CREATE TABLE IF NOT EXISTS `observation_summaries` (`version` text,`day` text,`checks` integer,`failed` integer," +
+			"`latency` integer,`max_latency` integer,`addresses` text,PRIMARY KEY (`version`,`day`))",
+	}
+
+	return execAll(tx, statements)
+}
+
+func createEventOutcomes(tx *gorm.DB) error {
+
+	statements := []string{
+		"CREATE TABLE IF NOT EXISTS `event_records` (`id` integer PRIMARY KEY AUTOINCREMENT,`timestamp` datetime,`version` text," +
+			"`type` text,`current` text,`previous` text,`source` text,`error` text,`payload` text)",
+		"CREATE INDEX IF NOT EXISTS `idx_event_records_timestamp` ON `event_records`(`timestamp`)",
+		"CREATE INDEX IF NOT EXISTS `idx_event_records_version` ON `event_records`(`version`)",
+		"CREATE TABLE IF NOT EXISTS `action_runs` (`id` integer PRIMARY KEY AUTOINCREMENT,`event_id` integer,`command` text," +
+			"`start` datetime,`end` datetime,`exit_code` integer,`stdout` text,`stderr` text,`timed_out` numeric,`error` text)",
+		"CREATE INDEX IF NOT EXISTS `idx_action_runs_event_id` ON `action_runs`(`event_id`)",
+		"CREATE TABLE IF NOT EXISTS `notification_attempts` (`id` integer PRIMARY KEY AUTOINCREMENT,`event_id` integer," +
+			"`timestamp` datetime,`channel` text,`recipient` text,`success` numeric,`error` text)",
+		"CREATE INDEX IF NOT EXISTS `idx_notification_attempts_event_id` ON `notification_attempts`(`event_id`)",
+	}
+
+	return execAll(tx, statements)
+}
+
+// execAll executes the statements in order, stopping on the first error
+func execAll(tx *gorm.DB, statements []string) error {
+
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
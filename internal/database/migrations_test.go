@@ -0,0 +1,86 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMigrate(t *testing.T) {
+
+	store := newGormStore(t)
+
+	applied, err := SchemaStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations) || applied[len(applied)-1].Version != LatestSchemaVersion() {
+		t.Fatalf("expected every migration to be applied, got %+v", applied)
+	}
+
+	if pending := PendingMigrations(applied); len(pending) != 0 {
+		t.Fatalf("expected no pending migrations, got %+v", pending)
+	}
+
+	again, err := Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 0 {
+		t.Fatalf("expected migrating again to apply nothing, got %+v", again)
+	}
+
+	if _, err = store.CreateEntry(entryAt(0, "v4", "203.0.113.1", "203.0.113.1")); err != nil {
+		t.Fatalf("could not create an entry on the migrated schema: %v", err)
+	}
+}
+
+func TestPendingMigrations(t *testing.T) {
+
+	if pending := PendingMigrations(nil); len(pending) != len(migrations) {
+		t.Fatalf("expected every migration to be pending on a new database, got %d", len(pending))
+	}
+
+	pending := PendingMigrations([]SchemaVersion{{Version: 1}, {Version: 2}})
+	if len(pending) != len(migrations)-2 || pending[0].Version != 3 {
+		t.Fatalf("expected the migrations from version 3 to be pending, got %+v", pending)
+	}
+}
+
+// TestRepairAddressEntries migrates a database whose 'address_entries' table was created by
+// AutoMigrate, when AddressEntry embedded gorm.Model
+func TestRepairAddressEntries(t *testing.T) {
+
+	store := newGormStore(t)
+	database := store.db
+
+	statements := []string{
+		"DROP TABLE `address_entries`",
+		"DELETE FROM `schema_version`",
+		"CREATE TABLE `address_entries` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` integer,`updated_at` datetime," +
+			"`deleted_at` datetime,`address` text,`previous_address` text,`version` text)",
+		"INSERT INTO `address_entries` (`created_at`,`address`,`previous_address`,`version`) VALUES (1,'203.0.113.1','203.0.113.1','v4')",
+		"INSERT INTO `address_entries` (`created_at`,`deleted_at`,`address`,`previous_address`,`version`) VALUES (2,'2024-01-01','203.0.113.2','203.0.113.1','v4')",
+	}
+	if err := execAll(database, statements); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if database.Migrator().HasColumn("address_entries", "deleted_at") {
+		t.Fatal("expected the 'deleted_at' column to be dropped")
+	}
+
+	// the soft deleted record is dropped
+	entries, err := store.Entries("v4", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectAddresses(t, entries, "203.0.113.1")
+
+	if _, err = store.CreateEntry(entryAt(0, "v4", "203.0.113.3", "203.0.113.1")); err != nil {
+		t.Fatalf("could not create an entry on the repaired table: %v", err)
+	}
+}
@@ -1,59 +1,68 @@
 package database
 
 import (
-	"gorm.io/gorm"
+	"strings"
 )
 
 type AddressEntry struct {
-	gorm.Model
-
 	// ID of the record, auto incremented uint64 value
-	ID uint64 `gorm:"primaryKey;autoIncrement:true"`
+	ID uint64 `gorm:"primaryKey;autoIncrement:true" json:"id"`
 
-	// Address is the newly fetched address that differs from the previous registered
+	// Address is the newly fetched address that differs from the previous registered, empty
+	// if the record marks a reset of the baseline
 	Address string `gorm:"index" json:"address"`
 	// PreviousAddress is the previous address, before the update
 	PreviousAddress string `json:"previous_address"`
 
+	// Prefix is the tracked IPv6 prefix of Address in CIDR notation, empty if not tracked
+	Prefix string `json:"prefix"`
+
+	// Addresses is the comma separated, sorted, snapshot of the address set, empty if not in set mode
+	Addresses string `json:"addresses"`
+	// Added are the comma separated addresses that joined the set since the previous snapshot
+	Added string `json:"added"`
+	// Removed are the comma separated addresses that left the set since the previous snapshot
+	Removed string `json:"removed"`
+
+	// Nat is the NAT status detected for the WAN side when the record was created, empty if not tracked
+	Nat string `json:"nat"`
+
 	// Version specifies the version of the address this record refers to
 	Version string `json:"version"`
 	// CreatedAt is the UNIX time when the address update was detected
 	CreatedAt uint64 `gorm:"autoCreateTime" json:"at"`
 }
 
-// Create is the function that creates a new AddressEntry record onto the database
-func (e AddressEntry) Create(address string, version string, previous string) (*AddressEntry, error) {
-
-	database := GetDatabase()
-	entry := AddressEntry{
-		Address:         address,
-		Version:         version,
-		PreviousAddress: previous,
-	}
+// IsReset reports whether the record marks a reset of the baseline, after which the next
+// check is handled as a first run. Reset markers have no address, the history before them is kept.
+func (e AddressEntry) IsReset() bool {
+	return e.Address == ""
+}
 
-	if err := database.Create(&entry).Error; err != nil {
-		return nil, err
+// IsChange reports whether the record is a change of the address (or of the address set),
+// rather than a first run, a seeded baseline or a reset marker, which store the address as
+// its own previous address and no set differences.
+func (e AddressEntry) IsChange() bool {
+	if e.IsReset() {
+		return false
 	}
-
-	return &entry, nil
+	return e.Address != e.PreviousAddress || e.Added != "" || e.Removed != ""
 }
 
-// First returns the latest added record for a specific address version (addressVersion)
-func (e AddressEntry) First(addressVersion string) (*AddressEntry, error) {
-
-	database := GetDatabase()
-
-	var entry AddressEntry
+// AddressSet returns the addresses of the stored set snapshot
+func (e AddressEntry) AddressSet() []string {
+	return SplitSet(e.Addresses)
+}
 
-	query := database.
-		Where("version = ?", addressVersion).
-		Order("created_at DESC").
-		First(&entry)
+// JoinSet serializes an address set to be stored on an AddressEntry
+func JoinSet(addresses []string) string {
+	return strings.Join(addresses, ",")
+}
 
-	if query.Error != nil {
-		return nil, query.Error
+// SplitSet deserializes an address set stored on an AddressEntry
+func SplitSet(addresses string) []string {
+	if addresses == "" {
+		return nil
 	}
-
-	return &entry, nil
-
+	return strings.Split(addresses, ",")
 }
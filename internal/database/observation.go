@@ -0,0 +1,46 @@
+package database
+
+import (
+	"time"
+)
+
+// Observation is the record of a single check, successful or not
+type Observation struct {
+	// ID of the record, auto incremented uint64 value
+	ID uint64 `gorm:"primaryKey;autoIncrement:true" json:"id"`
+
+	// Timestamp is the moment the check was made
+	Timestamp time.Time `gorm:"index" json:"timestamp"`
+	// Version specifies the version of the address that was checked
+	Version string `gorm:"index" json:"version"`
+	// Source is the url of the source that answered, empty if none did
+	Source string `json:"source"`
+
+	// Address is the observed address, or the comma separated address set in set mode,
+	// empty if the check failed
+	Address string `json:"address"`
+	// Error is the error that failed the check, empty if the check succeeded
+	Error string `json:"error"`
+
+	// Latency is the time, in milliseconds, taken to obtain the address
+	Latency int64 `json:"latency"`
+}
+
+// Uptime holds the number of checks made, and failed, within a period
+type Uptime struct {
+	// Version specifies the version of the address that was checked
+	Version string `json:"version"`
+	// Since is the start of the period
+	Since time.Time `json:"since"`
+
+	// Checks is the number of recorded checks
+	Checks int64 `json:"checks"`
+	// Failed is the number of recorded checks that failed
+	Failed int64 `json:"failed"`
+	// Ratio is the fraction of the recorded checks that succeeded, 0 if there are none
+	Ratio float64 `json:"ratio"`
+
+	// First and Last are the moments of the first and last recorded checks
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+}
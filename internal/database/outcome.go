@@ -0,0 +1,128 @@
+package database
+
+import (
+	"time"
+)
+
+// EventRecord is the record of a handled event, to which the actions run and the
+// notifications attempted on it refer
+type EventRecord struct {
+	// ID of the record, auto incremented uint64 value
+	ID uint64 `gorm:"primaryKey;autoIncrement:true" json:"id"`
+
+	// Timestamp is the moment the event happened
+	Timestamp time.Time `gorm:"index" json:"timestamp"`
+	// Version specifies the version of the address the event refers to
+	Version string `gorm:"index" json:"version"`
+	// Type is the type of the event, e.g. 'on_change'
+	Type string `json:"type"`
+
+	// Current is the observed address, or the comma separated address set in set mode
+	Current string `json:"current"`
+	// Previous is the previously committed address, only set on on_change
+	Previous string `json:"previous"`
+	// Source is the url of the source the address was obtained from
+	Source string `json:"source"`
+	// Error is the error that caused an on_error event, empty otherwise
+	Error string `json:"error"`
+
+	// Payload is the JSON encoded event, with the details only some events carry
+	Payload string `json:"payload"`
+}
+
+// ActionRun is the record of an action run on a handled event
+type ActionRun struct {
+	// ID of the record, auto incremented uint64 value
+	ID uint64 `gorm:"primaryKey;autoIncrement:true" json:"id"`
+	// EventID is the ID of the EventRecord the action was run on
+	EventID uint64 `gorm:"index" json:"event_id"`
+
+	// Command is the executable and arguments run
+	Command string `json:"command"`
+	// Start and End are the moments the action was started and finished
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// ExitCode is the exit code of the action, -1 if it did not exit (e.g. killed or not started)
+	ExitCode int `json:"exit_code"`
+	// Stdout and Stderr are the captured output of the action, truncated
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+	// TimedOut reports whether the action was killed for exceeding its execution time
+	TimedOut bool `json:"timed_out"`
+	// Error is the error that failed the action, empty if it succeeded
+	Error string `json:"error"`
+}
+
+// NotificationAttempt is the record of a notification of a handled event to a recipient
+type NotificationAttempt struct {
+	// ID of the record, auto incremented uint64 value
+	ID uint64 `gorm:"primaryKey;autoIncrement:true" json:"id"`
+	// EventID is the ID of the EventRecord the notification was about
+	EventID uint64 `gorm:"index" json:"event_id"`
+
+	// Timestamp is the moment the notification was attempted
+	Timestamp time.Time `json:"timestamp"`
+	// Channel is the channel the notification was sent through, e.g. 'email'
+	Channel string `json:"channel"`
+	// Recipient is the address the notification was sent to
+	Recipient string `json:"recipient"`
+
+	// Success reports whether the notification was delivered
+	Success bool `json:"success"`
+	// Error is the error that failed the notification, empty if it succeeded
+	Error string `json:"error"`
+}
+
+// EventOutcome is a handled event, with the actions run and the notifications attempted on it
+type EventOutcome struct {
+	EventRecord
+
+	// Actions are the actions run on the event, in order of start
+	Actions []ActionRun `json:"actions"`
+	// Notifications are the notifications attempted about the event, oldest first
+	Notifications []NotificationAttempt `json:"notifications"`
+}
+
+// Succeeded returns the number of actions that succeeded and of notifications delivered
+func (o EventOutcome) Succeeded() (actions int, notifications int) {
+
+	for _, run := range o.Actions {
+		if run.Error == "" {
+			actions++
+		}
+	}
+
+	for _, attempt := range o.Notifications {
+		if attempt.Success {
+			notifications++
+		}
+	}
+
+	return actions, notifications
+}
+
+// outcomesOf groups the runs and attempts by the records they refer to, keeping their order
+func outcomesOf(records []EventRecord, runs []ActionRun, attempts []NotificationAttempt) []EventOutcome {
+
+	outcomes := make([]EventOutcome, len(records))
+	index := make(map[uint64]int, len(records))
+	for i, record := range records {
+		outcomes[i] = EventOutcome{EventRecord: record}
+		index[record.ID] = i
+	}
+
+	for _, run := range runs {
+		if i, ok := index[run.EventID]; ok {
+			outcomes[i].Actions = append(outcomes[i].Actions, run)
+		}
+	}
+
+	for _, attempt := range attempts {
+		if i, ok := index[attempt.EventID]; ok {
+			outcomes[i].Notifications = append(outcomes[i].Notifications, attempt)
+		}
+	}
+
+	return outcomes
+}
@@ -0,0 +1,23 @@
+//go:build !purego
+
+package database
+
+import (
+	"fmt"
+	"net/url"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// open returns the dialector of the cgo SQLite driver (mattn/go-sqlite3), the default one
+func open(path string, wal bool, busyTimeout int) gorm.Dialector {
+
+	params := url.Values{}
+	params.Set("_busy_timeout", fmt.Sprint(busyTimeout))
+	if wal {
+		params.Set("_journal_mode", "WAL")
+	}
+
+	return sqlite.Open(fmt.Sprintf("file:%s?%s", path, params.Encode()))
+}
@@ -0,0 +1,24 @@
+//go:build purego
+
+package database
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// open returns the dialector of the pure Go SQLite driver (glebarez/sqlite), selected with
+// the 'purego' build tag to build without cgo, e.g. static binaries for routers.
+func open(path string, wal bool, busyTimeout int) gorm.Dialector {
+
+	params := url.Values{}
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout))
+	if wal {
+		params.Add("_pragma", "journal_mode(WAL)")
+	}
+
+	return sqlite.Open(fmt.Sprintf("file:%s?%s", path, params.Encode()))
+}
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"time"
+)
+
+// RuntimeState is the state of a watcher that outlives it, one record per address version,
+// restored on startup to carry on from where the previous run stopped.
+type RuntimeState struct {
+	// Version specifies the version of the address the watcher was watching
+	Version string `gorm:"primaryKey" json:"version"`
+
+	// ConsecutiveErrors is the number of consecutive failed checks, since FailingSince
+	ConsecutiveErrors int `json:"consecutive_errors"`
+	// FailingSince is the moment of the first of the consecutive failed checks, zero if not failing
+	FailingSince time.Time `json:"failing_since"`
+
+	// LastCheck is the moment of the latest check, failed or not
+	LastCheck time.Time `json:"last_check"`
+	// LastSuccess is the moment of the latest successful check
+	LastSuccess time.Time `json:"last_success"`
+	// StoppedAt is the moment the watcher was last stopped gracefully, zero if it never was
+	StoppedAt time.Time `json:"stopped_at"`
+
+	// SourceHealth is the JSON encoded health of each source, by source name
+	SourceHealth string `json:"source_health"`
+	// NatStatus is the last detected NAT status, so on_nat is not raised again on startup
+	NatStatus string `json:"nat_status"`
+}
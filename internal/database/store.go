@@ -0,0 +1,525 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Store persists the records of the watcher. GormStore stores them on the database,
+// MemoryStore keeps them in memory, for tests and ephemeral runs.
+type Store interface {
+	// CreateEntry creates a new AddressEntry record, with the next ID unless entry sets an
+	// unused one
+	CreateEntry(entry AddressEntry) (*AddressEntry, error)
+	// Latest returns the latest record for the version, or nil if there is none yet
+	Latest(version string) (*AddressEntry, error)
+	// Entries returns the records for the version created since the given moment, oldest first
+	Entries(version string, since time.Time) ([]AddressEntry, error)
+	// Changes returns the latest limit records for the version that are changes (see
+	// AddressEntry.IsChange), oldest first. A limit of 0 returns all of them.
+	Changes(version string, limit int) ([]AddressEntry, error)
+	// PruneEntries deletes the records of each version but the keep latest changes, the
+	// baselines they were made from and the latest record, returning the number of deleted
+	// records
+	PruneEntries(keep int) (int64, error)
+
+	// LoadState returns the runtime state saved for the version, or nil if none was saved yet
+	LoadState(version string) (*RuntimeState, error)
+	// SaveState creates or replaces the runtime state of its version
+	SaveState(state RuntimeState) error
+
+	// CreateObservation creates a new Observation record
+	CreateObservation(observation Observation) (*Observation, error)
+	// Observations returns the observations for the version made since the given moment,
+	// newest first. A limit of 0 returns all of them.
+	Observations(version string, since time.Time, limit int) ([]Observation, error)
+	// Uptime computes the Uptime of the version since the given moment, including the
+	// daily summaries of the expired observations
+	Uptime(version string, since time.Time) (*Uptime, error)
+	// PruneObservations deletes the observations made before the given moment, returning
+	// the number of deleted records
+	PruneObservations(before time.Time) (int64, error)
+	// SummarizeObservations downsamples the observations made before the given moment into
+	// daily summaries, deleting them. Returns the number of summarized observations.
+	SummarizeObservations(before time.Time) (int64, error)
+	// Summaries returns the daily summaries for the version since the given moment, oldest first
+	Summaries(version string, since time.Time) ([]ObservationSummary, error)
+
+	// CreateEventRecord creates a new EventRecord record
+	CreateEventRecord(record EventRecord) (*EventRecord, error)
+	// CreateActionRun creates a new ActionRun record
+	CreateActionRun(run ActionRun) (*ActionRun, error)
+	// CreateNotificationAttempt creates a new NotificationAttempt record
+	CreateNotificationAttempt(attempt NotificationAttempt) (*NotificationAttempt, error)
+	// Outcomes returns the handled events for the version since the given moment, with
+	// their outcomes, newest first. A limit of 0 returns all of them.
+	Outcomes(version string, since time.Time, limit int) ([]EventOutcome, error)
+	// Outcome returns the handled event with the given ID and its outcomes, or nil if there is none
+	Outcome(id uint64) (*EventOutcome, error)
+	// PruneOutcomes deletes the handled events that happened before the given moment, along
+	// with their outcomes, returning the number of deleted events
+	PruneOutcomes(before time.Time) (int64, error)
+
+	// Vacuum reclaims the space left by the deleted records
+	Vacuum() error
+
+	// Close releases the resources held by the store
+	Close() error
+}
+
+// GormStore is the Store backed by a gorm database
+type GormStore struct {
+	db *gorm.DB
+}
+
+var _ Store = (*GormStore)(nil)
+
+// NewGormStore creates a GormStore on db, whose schema is expected to be migrated
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+func (s *GormStore) CreateEntry(entry AddressEntry) (*AddressEntry, error) {
+
+	if err := s.db.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (s *GormStore) Latest(version string) (*AddressEntry, error) {
+
+	var entry AddressEntry
+
+	query := s.db.
+		Where("version = ?", version).
+		Order("created_at DESC").
+		Order("id DESC").
+		Limit(1).
+		Find(&entry)
+
+	if query.Error != nil {
+		return nil, query.Error
+	}
+
+	// Find, unlike First, does not log a missing record as an error
+	if query.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &entry, nil
+}
+
+func (s *GormStore) Entries(version string, since time.Time) ([]AddressEntry, error) {
+
+	var entries []AddressEntry
+
+	query := s.db.Where("version = ?", version)
+	if !since.IsZero() {
+		query = query.Where("created_at >= ?", since.Unix())
+	}
+
+	if err := query.Order("created_at ASC").Order("id ASC").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (s *GormStore) Changes(version string, limit int) ([]AddressEntry, error) {
+
+	var changes []AddressEntry
+
+	// matches AddressEntry.IsChange
+	query := s.db.
+		Where("version = ? AND address <> ''", version).
+		Where("address <> previous_address OR added <> '' OR removed <> ''").
+		Order("created_at DESC").
+		Order("id DESC")
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	if err := query.Find(&changes).Error; err != nil {
+		return nil, err
+	}
+
+	// restore the creation order
+	for i, j := 0, len(changes)-1; i < j; i, j = i+1, j-1 {
+		changes[i], changes[j] = changes[j], changes[i]
+	}
+
+	return changes, nil
+}
+
+func (s *GormStore) PruneEntries(keep int) (int64, error) {
+
+	var versions []string
+	if err := s.db.Model(&AddressEntry{}).Distinct().Pluck("version", &versions).Error; err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	for _, version := range versions {
+
+		var entries []AddressEntry
+		query := s.db.
+			Where("version = ?", version).
+			Order("created_at DESC").
+			Order("id DESC").
+			Find(&entries)
+
+		if query.Error != nil {
+			return deleted, query.Error
+		}
+
+		expired := expiredEntries(entries, keep)
+		if len(expired) == 0 {
+			continue
+		}
+
+		query = s.db.Where("id IN ?", expired).Delete(&AddressEntry{})
+		if query.Error != nil {
+			return deleted, query.Error
+		}
+		deleted += query.RowsAffected
+	}
+
+	return deleted, nil
+}
+
+// expiredEntries returns the IDs of the entries of a version, newest first, that are not
+// kept by PruneEntries: past the latest record, the keep latest changes are kept, along
+// with the first run, seeded baseline or reset marker each run of changes was made from.
+func expiredEntries(entries []AddressEntry, keep int) []uint64 {
+
+	var expired []uint64
+	changes, needsBaseline := 0, false
+
+	for i, entry := range entries {
+
+		switch {
+		case i == 0:
+			// the latest record is the baseline the next check is compared against
+			needsBaseline = entry.IsChange()
+			if entry.IsChange() {
+				changes++
+			}
+		case entry.IsChange() && changes < keep:
+			changes++
+			needsBaseline = true
+		case !entry.IsChange() && needsBaseline:
+			needsBaseline = false
+		default:
+			expired = append(expired, entry.ID)
+		}
+	}
+
+	return expired
+}
+
+func (s *GormStore) LoadState(version string) (*RuntimeState, error) {
+
+	var state RuntimeState
+
+	query := s.db.
+		Where("version = ?", version).
+		Limit(1).
+		Find(&state)
+
+	if query.Error != nil {
+		return nil, query.Error
+	}
+
+	if query.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &state, nil
+}
+
+func (s *GormStore) SaveState(state RuntimeState) error {
+	return s.db.Save(&state).Error
+}
+
+func (s *GormStore) CreateObservation(observation Observation) (*Observation, error) {
+
+	if err := s.db.Create(&observation).Error; err != nil {
+		return nil, err
+	}
+
+	return &observation, nil
+}
+
+func (s *GormStore) Observations(version string, since time.Time, limit int) ([]Observation, error) {
+
+	var observations []Observation
+
+	query := s.db.
+		Where("version = ? AND timestamp >= ?", version, since).
+		Order("timestamp DESC")
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	if err := query.Find(&observations).Error; err != nil {
+		return nil, err
+	}
+
+	return observations, nil
+}
+
+func (s *GormStore) Uptime(version string, since time.Time) (*Uptime, error) {
+
+	uptime := Uptime{Version: version, Since: since}
+
+	query := s.db.
+		Model(&Observation{}).
+		Where("version = ? AND timestamp >= ?", version, since)
+
+	if err := query.Count(&uptime.Checks).Error; err != nil {
+		return nil, err
+	}
+
+	if uptime.Checks == 0 {
+		return s.withSummaries(&uptime)
+	}
+
+	query = s.db.
+		Model(&Observation{}).
+		Where("version = ? AND timestamp >= ? AND error <> ''", version, since)
+
+	if err := query.Count(&uptime.Failed).Error; err != nil {
+		return nil, err
+	}
+
+	var first, last Observation
+
+	query = s.db.
+		Where("version = ? AND timestamp >= ?", version, since).
+		Order("timestamp ASC").
+		Limit(1).
+		Find(&first)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+
+	query = s.db.
+		Where("version = ? AND timestamp >= ?", version, since).
+		Order("timestamp DESC").
+		Limit(1).
+		Find(&last)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+
+	uptime.First, uptime.Last = first.Timestamp, last.Timestamp
+	uptime.Ratio = float64(uptime.Checks-uptime.Failed) / float64(uptime.Checks)
+
+	return s.withSummaries(&uptime)
+}
+
+// withSummaries adds the daily summaries of the expired observations to uptime
+func (s *GormStore) withSummaries(uptime *Uptime) (*Uptime, error) {
+
+	summaries, err := s.Summaries(uptime.Version, uptime.Since)
+	if err != nil {
+		return nil, err
+	}
+
+	addSummaries(uptime, summaries)
+	return uptime, nil
+}
+
+func (s *GormStore) PruneObservations(before time.Time) (int64, error) {
+
+	query := s.db.
+		Where("timestamp < ?", before).
+		Delete(&Observation{})
+
+	return query.RowsAffected, query.Error
+}
+
+func (s *GormStore) SummarizeObservations(before time.Time) (int64, error) {
+
+	var summarized int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+
+		var observations []Observation
+		if err := tx.Where("timestamp < ?", before).Order("timestamp ASC").Find(&observations).Error; err != nil {
+			return err
+		}
+
+		for _, summary := range summarize(observations) {
+
+			var existing ObservationSummary
+			query := tx.Where("version = ? AND day = ?", summary.Version, summary.Day).Limit(1).Find(&existing)
+			if query.Error != nil {
+				return query.Error
+			}
+
+			if query.RowsAffected > 0 {
+				summary = existing.merge(summary)
+			}
+
+			if err := tx.Save(&summary).Error; err != nil {
+				return err
+			}
+		}
+
+		query := tx.Where("timestamp < ?", before).Delete(&Observation{})
+		summarized = query.RowsAffected
+
+		return query.Error
+	})
+
+	return summarized, err
+}
+
+func (s *GormStore) Summaries(version string, since time.Time) ([]ObservationSummary, error) {
+
+	var summaries []ObservationSummary
+
+	query := s.db.
+		Where("version = ? AND day >= ?", version, since.UTC().Format(summaryDay)).
+		Order("day ASC").
+		Find(&summaries)
+
+	return summaries, query.Error
+}
+
+func (s *GormStore) CreateEventRecord(record EventRecord) (*EventRecord, error) {
+
+	if err := s.db.Create(&record).Error; err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+func (s *GormStore) CreateActionRun(run ActionRun) (*ActionRun, error) {
+
+	if err := s.db.Create(&run).Error; err != nil {
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+func (s *GormStore) CreateNotificationAttempt(attempt NotificationAttempt) (*NotificationAttempt, error) {
+
+	if err := s.db.Create(&attempt).Error; err != nil {
+		return nil, err
+	}
+
+	return &attempt, nil
+}
+
+func (s *GormStore) Outcomes(version string, since time.Time, limit int) ([]EventOutcome, error) {
+
+	var records []EventRecord
+
+	query := s.db.
+		Where("version = ? AND timestamp >= ?", version, since).
+		Order("timestamp DESC").
+		Order("id DESC")
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	if err := query.Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	return s.withOutcomes(records)
+}
+
+func (s *GormStore) Outcome(id uint64) (*EventOutcome, error) {
+
+	var records []EventRecord
+	if err := s.db.Where("id = ?", id).Limit(1).Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	outcomes, err := s.withOutcomes(records)
+	if err != nil {
+		return nil, err
+	}
+
+	return &outcomes[0], nil
+}
+
+// withOutcomes queries the actions run and the notifications attempted on records
+func (s *GormStore) withOutcomes(records []EventRecord) ([]EventOutcome, error) {
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint64, len(records))
+	for i, record := range records {
+		ids[i] = record.ID
+	}
+
+	var runs []ActionRun
+	if err := s.db.Where("event_id IN ?", ids).Order("start ASC").Order("id ASC").Find(&runs).Error; err != nil {
+		return nil, err
+	}
+
+	var attempts []NotificationAttempt
+	if err := s.db.Where("event_id IN ?", ids).Order("timestamp ASC").Order("id ASC").Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+
+	return outcomesOf(records, runs, attempts), nil
+}
+
+func (s *GormStore) PruneOutcomes(before time.Time) (int64, error) {
+
+	var deleted int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+
+		expired := tx.
+			Model(&EventRecord{}).
+			Select("id").
+			Where("timestamp < ?", before)
+
+		if err := tx.Where("event_id IN (?)", expired).Delete(&ActionRun{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("event_id IN (?)", expired).Delete(&NotificationAttempt{}).Error; err != nil {
+			return err
+		}
+
+		query := tx.Where("timestamp < ?", before).Delete(&EventRecord{})
+		deleted = query.RowsAffected
+
+		return query.Error
+	})
+
+	return deleted, err
+}
+
+func (s *GormStore) Vacuum() error {
+	return s.db.Exec("VACUUM").Error
+}
+
+func (s *GormStore) Close() error {
+
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.Close()
+}
@@ -0,0 +1,488 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+// base is the moment the records of the tests are created from
+var base = time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+// at returns the moment offset by minutes from base
+func at(minutes int) time.Time {
+	return base.Add(time.Duration(minutes) * time.Minute)
+}
+
+// entryAt returns the record of address, previously previous, created at the offset from base
+func entryAt(minutes int, version string, address string, previous string) AddressEntry {
+	return AddressEntry{
+		Address:         address,
+		PreviousAddress: previous,
+		Version:         version,
+		CreatedAt:       uint64(at(minutes).Unix()),
+	}
+}
+
+// newGormStore returns a GormStore on an in-memory SQLite database, migrated
+func newGormStore(t *testing.T) *GormStore {
+	return NewGormStore(newTestDatabase(t))
+}
+
+// stores are the implementations of Store the cases run against, each one created empty
+var stores = []struct {
+	name string
+	new  func(t *testing.T) Store
+}{
+	{"memory", func(t *testing.T) Store { return NewMemoryStore() }},
+	{"gorm", func(t *testing.T) Store { return newGormStore(t) }},
+}
+
+var storeCases = []struct {
+	name string
+	run  func(t *testing.T, store Store)
+}{
+	{"create entry", testCreateEntry},
+	{"latest", testLatest},
+	{"entries", testEntries},
+	{"changes", testChanges},
+	{"prune entries", testPruneEntries},
+	{"state", testState},
+	{"observations", testObservations},
+	{"summaries", testSummaries},
+	{"outcomes", testOutcomes},
+}
+
+// TestStores runs the same cases against every Store, which must behave alike
+func TestStores(t *testing.T) {
+	for _, c := range storeCases {
+		for _, s := range stores {
+			t.Run(c.name+"/"+s.name, func(t *testing.T) {
+				c.run(t, s.new(t))
+			})
+		}
+	}
+}
+
+// mustCreateEntries creates the entries in order, failing the test on an error
+func mustCreateEntries(t *testing.T, store Store, entries ...AddressEntry) {
+	t.Helper()
+
+	for _, entry := range entries {
+		if _, err := store.CreateEntry(entry); err != nil {
+			t.Fatalf("could not create the entry: %v", err)
+		}
+	}
+}
+
+// addressesOf returns the addresses of entries, in order
+func addressesOf(entries []AddressEntry) []string {
+
+	addresses := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		addresses = append(addresses, entry.Address)
+	}
+
+	return addresses
+}
+
+func expectAddresses(t *testing.T, entries []AddressEntry, expected ...string) {
+	t.Helper()
+
+	got := addressesOf(entries)
+	if JoinSet(got) != JoinSet(expected) {
+		t.Fatalf("expected the addresses %v, got %v", expected, got)
+	}
+}
+
+func testCreateEntry(t *testing.T, store Store) {
+
+	first, err := store.CreateEntry(entryAt(0, "v4", "203.0.113.1", "203.0.113.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an ID set on the entry is kept, the next ones following it
+	imported := entryAt(1, "v4", "203.0.113.2", "203.0.113.1")
+	imported.ID = first.ID + 10
+	created, err := store.CreateEntry(imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID != imported.ID {
+		t.Fatalf("expected the ID %d to be kept, got %d", imported.ID, created.ID)
+	}
+
+	next, err := store.CreateEntry(entryAt(2, "v4", "203.0.113.3", "203.0.113.2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.ID <= imported.ID {
+		t.Fatalf("expected an ID after %d, got %d", imported.ID, next.ID)
+	}
+
+	if _, err = store.CreateEntry(imported); err == nil {
+		t.Fatal("expected creating an entry with a used ID to fail")
+	}
+}
+
+func testLatest(t *testing.T, store Store) {
+
+	latest, err := store.Latest("v4")
+	if err != nil || latest != nil {
+		t.Fatalf("expected no latest entry on an empty store, got %v (%v)", latest, err)
+	}
+
+	mustCreateEntries(t, store,
+		entryAt(0, "v4", "203.0.113.1", "203.0.113.1"),
+		entryAt(1, "v4", "203.0.113.2", "203.0.113.1"),
+		entryAt(2, "v6", "2001:db8::1", "2001:db8::1"),
+		// created within the same second, the last created is the latest
+		entryAt(1, "v4", "203.0.113.3", "203.0.113.2"),
+	)
+
+	latest, err = store.Latest("v4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest == nil || latest.Address != "203.0.113.3" {
+		t.Fatalf("expected the latest v4 entry to be 203.0.113.3, got %v", latest)
+	}
+
+	latest, err = store.Latest("v6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest == nil || latest.Address != "2001:db8::1" {
+		t.Fatalf("expected the latest v6 entry to be 2001:db8::1, got %v", latest)
+	}
+}
+
+func testEntries(t *testing.T, store Store) {
+
+	mustCreateEntries(t, store,
+		entryAt(0, "v4", "203.0.113.1", "203.0.113.1"),
+		entryAt(10, "v6", "2001:db8::1", "2001:db8::1"),
+		entryAt(20, "v4", "203.0.113.2", "203.0.113.1"),
+		entryAt(30, "v4", "203.0.113.3", "203.0.113.2"),
+	)
+
+	entries, err := store.Entries("v4", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectAddresses(t, entries, "203.0.113.1", "203.0.113.2", "203.0.113.3")
+
+	entries, err = store.Entries("v4", at(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectAddresses(t, entries, "203.0.113.2", "203.0.113.3")
+
+	entries, err = store.Entries("v4", at(31))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectAddresses(t, entries)
+}
+
+func testChanges(t *testing.T, store Store) {
+
+	snapshot := entryAt(4, "v4", "203.0.113.4", "203.0.113.4")
+	snapshot.Addresses = "203.0.113.4,203.0.113.5"
+	snapshot.Added = "203.0.113.5"
+
+	mustCreateEntries(t, store,
+		entryAt(0, "v4", "203.0.113.1", "203.0.113.1"), // first run
+		entryAt(1, "v4", "203.0.113.2", "203.0.113.1"), // change
+		entryAt(2, "v4", "", "203.0.113.2"),            // reset marker
+		entryAt(3, "v4", "203.0.113.4", "203.0.113.4"), // first run after the reset
+		snapshot, // an address joined the set
+		entryAt(5, "v4", "203.0.113.6", "203.0.113.4"), // change
+		entryAt(6, "v6", "2001:db8::2", "2001:db8::1"), // change of another version
+	)
+
+	changes, err := store.Changes("v4", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectAddresses(t, changes, "203.0.113.2", "203.0.113.4", "203.0.113.6")
+
+	changes, err = store.Changes("v4", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectAddresses(t, changes, "203.0.113.4", "203.0.113.6")
+	if changes[0].Added != "203.0.113.5" {
+		t.Fatalf("expected the set change to keep the added addresses, got '%v'", changes[0].Added)
+	}
+}
+
+func testPruneEntries(t *testing.T, store Store) {
+
+	mustCreateEntries(t, store,
+		entryAt(10, "v4", "203.0.113.2", "203.0.113.1"), // change
+		entryAt(20, "v4", "203.0.113.3", "203.0.113.2"), // change
+		entryAt(30, "v4", "203.0.113.4", "203.0.113.3"), // change
+		entryAt(40, "v4", "", "203.0.113.4"),            // reset marker
+		entryAt(50, "v4", "203.0.113.5", "203.0.113.5"), // first run after the reset
+		entryAt(5, "v6", "2001:db8::1", "2001:db8::1"),  // first run of another version
+		// created last, but the oldest, the first run the changes were made from
+		entryAt(0, "v4", "203.0.113.1", "203.0.113.1"),
+	)
+
+	deleted, err := store.PruneEntries(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Fatalf("expected 2 deleted entries, got %d", deleted)
+	}
+
+	// the latest record, the 2 latest changes and the first run they were made from
+	entries, err := store.Entries("v4", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectAddresses(t, entries, "203.0.113.1", "203.0.113.3", "203.0.113.4", "203.0.113.5")
+
+	entries, err = store.Entries("v6", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectAddresses(t, entries, "2001:db8::1")
+}
+
+func testState(t *testing.T, store Store) {
+
+	state, err := store.LoadState("v4")
+	if err != nil || state != nil {
+		t.Fatalf("expected no state on an empty store, got %v (%v)", state, err)
+	}
+
+	saved := RuntimeState{
+		Version:           "v4",
+		ConsecutiveErrors: 2,
+		FailingSince:      at(0),
+		LastCheck:         at(5),
+		SourceHealth:      `{"ipify":{}}`,
+	}
+	if err = store.SaveState(saved); err != nil {
+		t.Fatal(err)
+	}
+
+	saved.ConsecutiveErrors = 0
+	saved.FailingSince = time.Time{}
+	saved.LastSuccess = at(10)
+	if err = store.SaveState(saved); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = store.LoadState("v4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state == nil {
+		t.Fatal("expected the saved state")
+	}
+	if state.ConsecutiveErrors != 0 || !state.FailingSince.IsZero() || !state.LastCheck.Equal(at(5)) ||
+		!state.LastSuccess.Equal(at(10)) || state.SourceHealth != saved.SourceHealth {
+		t.Fatalf("expected the state to be replaced by %+v, got %+v", saved, *state)
+	}
+
+	if state, err = store.LoadState("v6"); err != nil || state != nil {
+		t.Fatalf("expected no v6 state, got %v (%v)", state, err)
+	}
+}
+
+// mustCreateObservations creates the observations in order, failing the test on an error
+func mustCreateObservations(t *testing.T, store Store, observations ...Observation) {
+	t.Helper()
+
+	for _, observation := range observations {
+		if _, err := store.CreateObservation(observation); err != nil {
+			t.Fatalf("could not create the observation: %v", err)
+		}
+	}
+}
+
+func testObservations(t *testing.T, store Store) {
+
+	mustCreateObservations(t, store,
+		Observation{Timestamp: at(0), Version: "v4", Address: "203.0.113.1", Latency: 10},
+		Observation{Timestamp: at(1), Version: "v4", Error: "timeout", Latency: 30},
+		Observation{Timestamp: at(2), Version: "v6", Address: "2001:db8::1", Latency: 20},
+		Observation{Timestamp: at(3), Version: "v4", Address: "203.0.113.1", Latency: 20},
+	)
+
+	observations, err := store.Observations("v4", time.Time{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(observations) != 3 || !observations[0].Timestamp.Equal(at(3)) || !observations[2].Timestamp.Equal(at(0)) {
+		t.Fatalf("expected the 3 v4 observations, newest first, got %+v", observations)
+	}
+
+	observations, err = store.Observations("v4", time.Time{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(observations) != 2 || !observations[1].Timestamp.Equal(at(1)) {
+		t.Fatalf("expected the 2 newest v4 observations, got %+v", observations)
+	}
+
+	uptime, err := store.Uptime("v4", at(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uptime.Checks != 2 || uptime.Failed != 1 || uptime.Ratio != 0.5 ||
+		!uptime.First.Equal(at(1)) || !uptime.Last.Equal(at(3)) {
+		t.Fatalf("expected 2 checks, 1 failed, from %v to %v, got %+v", at(1), at(3), *uptime)
+	}
+
+	deleted, err := store.PruneObservations(at(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Fatalf("expected 2 pruned observations, got %d", deleted)
+	}
+
+	uptime, err = store.Uptime("v4", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uptime.Checks != 1 || uptime.Failed != 0 {
+		t.Fatalf("expected 1 check left, got %+v", *uptime)
+	}
+}
+
+func testSummaries(t *testing.T, store Store) {
+
+	day := 24 * 60
+	mustCreateObservations(t, store,
+		Observation{Timestamp: at(0), Version: "v4", Address: "203.0.113.1", Latency: 10},
+		Observation{Timestamp: at(1), Version: "v4", Error: "timeout", Latency: 30},
+		Observation{Timestamp: at(2), Version: "v4", Address: "203.0.113.2", Latency: 20},
+		Observation{Timestamp: at(day), Version: "v4", Address: "203.0.113.2", Latency: 10},
+	)
+
+	summarized, err := store.SummarizeObservations(at(day))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summarized != 3 {
+		t.Fatalf("expected 3 summarized observations, got %d", summarized)
+	}
+
+	// summarizing again merges into the existing summary of the day
+	mustCreateObservations(t, store,
+		Observation{Timestamp: at(3), Version: "v4", Address: "203.0.113.3", Latency: 40},
+	)
+	if _, err = store.SummarizeObservations(at(day)); err != nil {
+		t.Fatal(err)
+	}
+
+	summaries, err := store.Summaries("v4", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 {
+		t.Fatalf("expected a single summary, got %+v", summaries)
+	}
+
+	summary := summaries[0]
+	if summary.Day != "2024-03-10" || summary.Checks != 4 || summary.Failed != 1 || summary.Latency != 25 ||
+		summary.MaxLatency != 40 || summary.Addresses != "203.0.113.1,203.0.113.2,203.0.113.3" {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+
+	uptime, err := store.Uptime("v4", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uptime.Checks != 5 || uptime.Failed != 1 || !uptime.First.Equal(summary.Start()) || !uptime.Last.Equal(at(day)) {
+		t.Fatalf("expected the uptime to include the summary, got %+v", *uptime)
+	}
+}
+
+func testOutcomes(t *testing.T, store Store) {
+
+	first, err := store.CreateEventRecord(EventRecord{Timestamp: at(0), Version: "v4", Type: "on_change", Current: "203.0.113.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.CreateEventRecord(EventRecord{Timestamp: at(1), Version: "v4", Type: "on_error", Error: "timeout"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.CreateEventRecord(EventRecord{Timestamp: at(2), Version: "v6", Type: "on_match"}); err != nil {
+		t.Fatal(err)
+	}
+
+	runs := []ActionRun{
+		{EventID: first.ID, Command: "late", Start: at(5), Error: "exit status 1", ExitCode: 1},
+		{EventID: first.ID, Command: "early", Start: at(4)},
+	}
+	for _, run := range runs {
+		if _, err = store.CreateActionRun(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	attempt := NotificationAttempt{EventID: second.ID, Timestamp: at(1), Channel: "email", Recipient: "ops@example.com", Success: true}
+	if _, err = store.CreateNotificationAttempt(attempt); err != nil {
+		t.Fatal(err)
+	}
+
+	outcomes, err := store.Outcomes("v4", time.Time{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outcomes) != 2 || outcomes[0].ID != second.ID || outcomes[1].ID != first.ID {
+		t.Fatalf("expected the 2 v4 events, newest first, got %+v", outcomes)
+	}
+
+	if actions, notifications := outcomes[0].Succeeded(); actions != 0 || notifications != 1 {
+		t.Fatalf("expected 0 actions and 1 notification to succeed, got %d and %d", actions, notifications)
+	}
+
+	changed := outcomes[1]
+	if len(changed.Actions) != 2 || changed.Actions[0].Command != "early" || changed.Actions[1].Command != "late" {
+		t.Fatalf("expected the actions in order of start, got %+v", changed.Actions)
+	}
+	if actions, notifications := changed.Succeeded(); actions != 1 || notifications != 0 {
+		t.Fatalf("expected 1 action and 0 notifications to succeed, got %d and %d", actions, notifications)
+	}
+
+	outcomes, err = store.Outcomes("v4", time.Time{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outcomes) != 1 || outcomes[0].ID != second.ID {
+		t.Fatalf("expected only the newest v4 event, got %+v", outcomes)
+	}
+
+	outcome, err := store.Outcome(first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outcome == nil || outcome.Current != "203.0.113.2" || len(outcome.Actions) != 2 {
+		t.Fatalf("expected the first event with its actions, got %+v", outcome)
+	}
+
+	if outcome, err = store.Outcome(1000); err != nil || outcome != nil {
+		t.Fatalf("expected no event with an unknown id, got %v (%v)", outcome, err)
+	}
+
+	deleted, err := store.PruneOutcomes(at(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Fatalf("expected 1 pruned event, got %d", deleted)
+	}
+
+	if outcome, err = store.Outcome(first.ID); err != nil || outcome != nil {
+		t.Fatalf("expected the pruned event to be gone, got %v (%v)", outcome, err)
+	}
+}
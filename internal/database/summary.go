@@ -0,0 +1,134 @@
+package database
+
+import (
+	"sort"
+	"time"
+)
+
+// summaryDay is the layout of ObservationSummary.Day
+const summaryDay = "2006-01-02"
+
+// ObservationSummary is the daily summary of the observations of a version, downsampled
+// from the observations once they expire
+type ObservationSummary struct {
+	// Version specifies the version of the address that was checked
+	Version string `gorm:"primaryKey" json:"version"`
+	// Day is the UTC day summarized, in the 'YYYY-MM-DD' format
+	Day string `gorm:"primaryKey" json:"day"`
+
+	// Checks is the number of checks made on the day
+	Checks int64 `json:"checks"`
+	// Failed is the number of checks that failed on the day
+	Failed int64 `json:"failed"`
+
+	// Latency is the mean latency, in milliseconds, of the checks made on the day
+	Latency int64 `json:"latency"`
+	// MaxLatency is the maximum latency, in milliseconds, of the checks made on the day
+	MaxLatency int64 `json:"max_latency"`
+
+	// Addresses are the comma separated, sorted, distinct addresses observed on the day
+	Addresses string `json:"addresses"`
+}
+
+// Start returns the moment the summarized day starts
+func (s ObservationSummary) Start() time.Time {
+	day, _ := time.Parse(summaryDay, s.Day)
+	return day
+}
+
+// merge adds the checks summarized by other, of the same version and day, to the summary
+func (s ObservationSummary) merge(other ObservationSummary) ObservationSummary {
+
+	checks := s.Checks + other.Checks
+	if checks > 0 {
+		s.Latency = (s.Latency*s.Checks + other.Latency*other.Checks) / checks
+	}
+	s.Checks = checks
+	s.Failed += other.Failed
+	s.MaxLatency = max(s.MaxLatency, other.MaxLatency)
+
+	addresses := map[string]bool{}
+	for _, address := range append(SplitSet(s.Addresses), SplitSet(other.Addresses)...) {
+		addresses[address] = true
+	}
+	s.Addresses = joinDistinct(addresses)
+
+	return s
+}
+
+// summarize downsamples observations into a summary per version and UTC day
+func summarize(observations []Observation) []ObservationSummary {
+
+	type key struct{ version, day string }
+
+	summaries := map[key]ObservationSummary{}
+	var order []key
+
+	for _, observation := range observations {
+
+		k := key{observation.Version, observation.Timestamp.UTC().Format(summaryDay)}
+
+		summary := ObservationSummary{
+			Version:    k.version,
+			Day:        k.day,
+			Checks:     1,
+			Latency:    observation.Latency,
+			MaxLatency: observation.Latency,
+		}
+		if observation.Error != "" {
+			summary.Failed = 1
+		} else if observation.Address != "" {
+			summary.Addresses = observation.Address
+		}
+
+		if existing, ok := summaries[k]; ok {
+			summary = existing.merge(summary)
+		} else {
+			order = append(order, k)
+		}
+		summaries[k] = summary
+	}
+
+	result := make([]ObservationSummary, 0, len(order))
+	for _, k := range order {
+		result = append(result, summaries[k])
+	}
+
+	return result
+}
+
+// addSummaries adds the checks of the summaries to uptime, the summaries being of its version
+func addSummaries(uptime *Uptime, summaries []ObservationSummary) {
+
+	// the summaries are older than the observations, only bounding the period without them
+	observed := uptime.Checks > 0
+
+	for _, summary := range summaries {
+
+		uptime.Checks += summary.Checks
+		uptime.Failed += summary.Failed
+
+		if start := summary.Start(); uptime.First.IsZero() || start.Before(uptime.First) {
+			uptime.First = start
+		}
+		if end := summary.Start().AddDate(0, 0, 1); !observed && end.After(uptime.Last) {
+			uptime.Last = end
+		}
+	}
+
+	if uptime.Checks > 0 {
+		uptime.Ratio = float64(uptime.Checks-uptime.Failed) / float64(uptime.Checks)
+	}
+}
+
+// joinDistinct joins the addresses of the set, sorted
+func joinDistinct(addresses map[string]bool) string {
+
+	sorted := make([]string, 0, len(addresses))
+	for address := range addresses {
+		sorted = append(sorted, address)
+	}
+	sort.Strings(sorted)
+
+	return JoinSet(sorted)
+}
@@ -0,0 +1,348 @@
+package watcher
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/config"
+	"github.com/gweebg/ipwatcher/internal/database"
+	"github.com/rs/zerolog"
+)
+
+// apiRecentEvents is the number of recent events kept to be served by the api
+const apiRecentEvents = 100
+
+// Api exposes information relative to the watcher over HTTP, on the port
+// defined at 'watcher.api.port'.
+type Api struct {
+	// Host the api listens on, 'watcher.api.host', loopback by default
+	Host string
+	// Port the api listens on
+	Port int
+	// Version of the IP protocol whose baseline is managed on '/baseline'
+	Version string
+
+	metrics *Metrics
+	store   database.Store
+	server  *http.Server
+
+	// token is required, as a bearer token, by the endpoints that change the records,
+	// which are disabled if empty
+	token string
+
+	// events are the most recent events, oldest first
+	events   []Event
+	eventsMu sync.RWMutex
+
+	logger zerolog.Logger
+}
+
+// NewApi creates the Api, serving the given metrics alongside the recent events and
+// the records of store
+func NewApi(metrics *Metrics, store database.Store) *Api {
+
+	c := config.GetConfig()
+
+	host := "127.0.0.1"
+	if c.IsSet("watcher.api.host") {
+		host = c.GetString("watcher.api.host")
+	}
+
+	a := &Api{
+		Host:    host,
+		Port:    c.GetInt("watcher.api.port"),
+		Version: c.GetString("flags.version"),
+		metrics: metrics,
+		store:   store,
+		token:   c.GetString("watcher.api.token"),
+		logger:  GetLogger().With().Str("service", "api").Logger(),
+	}
+
+	if a.token == "" {
+		a.logger.Warn().Msg("'watcher.api.token' is not set, the endpoints changing the baseline are disabled")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", a.handleEvents)
+	mux.HandleFunc("/metrics", a.handleMetrics)
+	mux.HandleFunc("/baseline", a.handleBaseline)
+	mux.HandleFunc("/observations", a.handleObservations)
+	mux.HandleFunc("/uptime", a.handleUptime)
+	mux.HandleFunc("/stats", a.handleStats)
+	mux.HandleFunc("/prediction", a.handlePrediction)
+	mux.HandleFunc("/outcomes", a.handleOutcomes)
+
+	a.server = &http.Server{
+		Addr:    net.JoinHostPort(a.Host, strconv.Itoa(a.Port)),
+		Handler: mux,
+	}
+
+	return a
+}
+
+// Record keeps event to be served on '/events', subscribed to the bus
+func (a *Api) Record(event Event) {
+
+	a.eventsMu.Lock()
+	defer a.eventsMu.Unlock()
+
+	a.events = append(a.events, event)
+	if len(a.events) > apiRecentEvents {
+		a.events = a.events[len(a.events)-apiRecentEvents:]
+	}
+}
+
+// Serve listens for requests until Shutdown is called
+func (a *Api) Serve() {
+
+	a.logger.Info().Msgf("api listening on %v", a.server.Addr)
+
+	err := a.server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		a.logger.Error().Err(err).Msg("api stopped unexpectedly")
+	}
+}
+
+// Shutdown stops the api, waiting for the active requests until ctx is done
+func (a *Api) Shutdown(ctx context.Context) error {
+	return a.server.Shutdown(ctx)
+}
+
+func (a *Api) handleEvents(w http.ResponseWriter, r *http.Request) {
+
+	a.eventsMu.RLock()
+	events := append([]Event{}, a.events...)
+	a.eventsMu.RUnlock()
+
+	a.respond(w, events)
+}
+
+func (a *Api) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	a.respond(w, a.metrics.Snapshot())
+}
+
+// handleObservations serves the recorded checks, newest first, filtered by the 'since'
+// and 'limit' (100 by default) query parameters.
+func (a *Api) handleObservations(w http.ResponseWriter, r *http.Request) {
+
+	since, err := ParseSince(r.URL.Query().Get("since"), time.Now())
+	if err != nil {
+		a.respondError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limit := 100
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+			a.respondError(w, http.StatusBadRequest, fmt.Errorf("'%v' is not a valid limit", value))
+			return
+		}
+	}
+
+	history, err := a.store.Observations(a.Version, since, limit)
+	if err != nil {
+		a.respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	a.respond(w, history)
+}
+
+// handleUptime serves the uptime since the 'since' query parameter, the last 24 hours by default
+func (a *Api) handleUptime(w http.ResponseWriter, r *http.Request) {
+
+	value := r.URL.Query().Get("since")
+	if value == "" {
+		value = "24h"
+	}
+
+	since, err := ParseSince(value, time.Now())
+	if err != nil {
+		a.respondError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	uptime, err := a.store.Uptime(a.Version, since)
+	if err != nil {
+		a.respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	a.respond(w, uptime)
+}
+
+// handleStats serves the statistics of the address changes since the 'since' query
+// parameter, the whole history by default
+func (a *Api) handleStats(w http.ResponseWriter, r *http.Request) {
+
+	since, err := ParseSince(r.URL.Query().Get("since"), time.Now())
+	if err != nil {
+		a.respondError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	entries, err := a.store.Entries(a.Version, since)
+	if err != nil {
+		a.respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	a.respond(w, ComputeStats(a.Version, entries, time.Now()))
+}
+
+// handlePrediction serves the predicted next change window, null if the changes are not periodic
+func (a *Api) handlePrediction(w http.ResponseWriter, r *http.Request) {
+
+	prediction, err := PredictFrom(a.store, a.Version, time.Now())
+	if err != nil {
+		a.respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	a.respond(w, prediction)
+}
+
+// handleOutcomes serves the handled events, newest first, with the actions run and the
+// notifications attempted on them, filtered by the 'since' and 'limit' (100 by default)
+// query parameters. Only the event with the given 'id' is served, if set.
+func (a *Api) handleOutcomes(w http.ResponseWriter, r *http.Request) {
+
+	if value := r.URL.Query().Get("id"); value != "" {
+
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			a.respondError(w, http.StatusBadRequest, fmt.Errorf("'%v' is not a valid id", value))
+			return
+		}
+
+		outcome, err := a.store.Outcome(id)
+		if err != nil {
+			a.respondError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if outcome == nil {
+			a.respondError(w, http.StatusNotFound, fmt.Errorf("no handled event with id %d", id))
+			return
+		}
+
+		a.respond(w, outcome)
+		return
+	}
+
+	since, err := ParseSince(r.URL.Query().Get("since"), time.Now())
+	if err != nil {
+		a.respondError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limit := 100
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+			a.respondError(w, http.StatusBadRequest, fmt.Errorf("'%v' is not a valid limit", value))
+			return
+		}
+	}
+
+	outcomes, err := a.store.Outcomes(a.Version, since, limit)
+	if err != nil {
+		a.respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	a.respond(w, outcomes)
+}
+
+// baselineRequest is the body of a 'POST /baseline' request
+type baselineRequest struct {
+	Address string `json:"address"`
+}
+
+// handleBaseline seeds the baseline address on POST, and resets it on DELETE, both
+// requiring the token
+func (a *Api) handleBaseline(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method == http.MethodPost || r.Method == http.MethodDelete {
+
+		if a.token == "" {
+			a.respondError(w, http.StatusForbidden, errors.New("set 'watcher.api.token' to change the baseline through the api"))
+			return
+		}
+
+		if !a.authorized(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			a.respondError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+			return
+		}
+	}
+
+	switch r.Method {
+
+	case http.MethodPost:
+
+		var body baselineRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			a.respondError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+
+		entry, err := SeedBaseline(a.store, a.Version, body.Address)
+		if err != nil {
+			a.respondError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		a.logger.Info().Str("address", entry.Address).Msg("baseline seeded through the api")
+		a.respond(w, entry)
+
+	case http.MethodDelete:
+
+		marker, err := ResetBaseline(a.store, a.Version)
+		if err != nil {
+			a.respondError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		a.logger.Info().Msg("baseline reset through the api")
+		a.respond(w, marker)
+
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		a.respondError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// authorized reports whether the request carries the token as 'Authorization: Bearer <token>'
+func (a *Api) authorized(r *http.Request) bool {
+
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1
+}
+
+// respondError writes err as a JSON response with the given status code
+func (a *Api) respondError(w http.ResponseWriter, status int, err error) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(map[string]string{"error": err.Error()}); err != nil {
+		a.logger.Error().Err(err).Msg("cannot encode response")
+	}
+}
+
+// respond writes body as a JSON response
+func (a *Api) respond(w http.ResponseWriter, body any) {
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		a.logger.Error().Err(err).Msg("cannot encode response")
+	}
+}
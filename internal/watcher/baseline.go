@@ -0,0 +1,59 @@
+package watcher
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/gweebg/ipwatcher/internal/database"
+)
+
+// SeedBaseline records address on store as the current baseline for the version, the
+// next check is compared against it. The baseline is not a change of the address, so it
+// is recorded as its own previous address.
+func SeedBaseline(store database.Store, version string, address string) (*database.AddressEntry, error) {
+
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, fmt.Errorf("'%v' is not a valid IP address", address)
+	}
+
+	isV4 := ip.To4() != nil
+	if (version == "v4") != isV4 {
+		return nil, fmt.Errorf("'%v' is not an IP%v address", address, version)
+	}
+
+	return store.CreateEntry(database.AddressEntry{
+		Address:         ip.String(),
+		PreviousAddress: ip.String(),
+		Version:         version,
+	})
+}
+
+// ResetBaseline records a reset marker for the version on store, so that the next check
+// handles its address as a first run. The recorded history is kept.
+func ResetBaseline(store database.Store, version string) (*database.AddressEntry, error) {
+
+	previous, err := store.Latest(version)
+	if err != nil {
+		return nil, err
+	}
+
+	marker := database.AddressEntry{Version: version}
+	if previous != nil {
+		marker.PreviousAddress = previous.Address
+	}
+
+	return store.CreateEntry(marker)
+}
+
+// latestBaseline returns the latest record of the version on store, against which checks
+// are compared, or nil if there is none or the baseline was reset
+func latestBaseline(store database.Store, version string) (*database.AddressEntry, error) {
+
+	latest, err := store.Latest(version)
+	if err != nil || latest == nil || latest.IsReset() {
+		return nil, err
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,160 @@
+package watcher
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// busBufferSize is the number of events each subscriber can have pending
+const busBufferSize = 64
+
+// busDeliveryTimeout is how long Publish waits on the full buffer of a reliable subscriber
+// before dropping the event for it
+const busDeliveryTimeout = 30 * time.Second
+
+// Bus is a buffered publish/subscribe event bus. Each subscriber consumes the events
+// independently, on its own goroutine and buffer, so a slow subscriber does not hold
+// back the others nor the publisher, unless it subscribed reliably.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []*subscriber
+	closed      bool
+
+	// publishing tracks the events being delivered, waited for on Close before closing
+	// the subscriber buffers
+	publishing sync.WaitGroup
+	running    sync.WaitGroup
+	logger     zerolog.Logger
+}
+
+type subscriber struct {
+	name    string
+	events  chan Event
+	handler func(Event)
+	// reliable subscribers are waited on when their buffer is full, instead of dropping the event
+	reliable bool
+}
+
+// NewBus creates an empty Bus
+func NewBus() *Bus {
+	return &Bus{
+		logger: GetLogger().With().Str("service", "bus").Logger(),
+	}
+}
+
+// Subscribe registers handler to be called, in order, with every published event. Events
+// are dropped for the subscriber when it falls behind.
+func (b *Bus) Subscribe(name string, handler func(Event)) {
+	b.subscribe(name, handler, false)
+}
+
+// SubscribeReliable registers handler like Subscribe, but when the subscriber falls behind
+// the publisher waits up to busDeliveryTimeout for it, instead of dropping the event.
+func (b *Bus) SubscribeReliable(name string, handler func(Event)) {
+	b.subscribe(name, handler, true)
+}
+
+func (b *Bus) subscribe(name string, handler func(Event), reliable bool) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := &subscriber{
+		name:     name,
+		events:   make(chan Event, busBufferSize),
+		handler:  handler,
+		reliable: reliable,
+	}
+	b.subscribers = append(b.subscribers, s)
+
+	b.running.Add(1)
+	go func() {
+		defer b.running.Done()
+		for event := range s.events {
+			s.handler(event)
+		}
+	}()
+}
+
+// Publish delivers event to every subscriber. If the buffer of a subscriber is full, the
+// event is dropped for that subscriber, or, for a reliable one, dropped only once
+// busDeliveryTimeout passes without the subscriber catching up.
+func (b *Bus) Publish(event Event) {
+
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		b.logger.Warn().Str("event", string(event.Type)).Err(event.Err).Msg("bus is closed, dropping event")
+		return
+	}
+
+	// delivered without holding the lock, as waiting on a reliable subscriber would
+	// otherwise hold back Subscribe and Close
+	subscribers := make([]*subscriber, len(b.subscribers))
+	copy(subscribers, b.subscribers)
+	b.publishing.Add(1)
+	b.mu.RUnlock()
+
+	defer b.publishing.Done()
+
+	for _, s := range subscribers {
+		select {
+		case s.events <- event:
+			continue
+		default:
+		}
+
+		if s.reliable && b.deliver(s, event) {
+			continue
+		}
+
+		b.logger.Warn().
+			Str("event", string(event.Type)).
+			Str("subscriber", s.name).
+			Msg("subscriber is falling behind, dropping event")
+	}
+}
+
+// deliver waits up to busDeliveryTimeout for the subscriber to take event, reporting
+// whether it did
+func (b *Bus) deliver(s *subscriber, event Event) bool {
+
+	timer := time.NewTimer(busDeliveryTimeout)
+	defer timer.Stop()
+
+	b.logger.Debug().
+		Str("event", string(event.Type)).
+		Str("subscriber", s.name).
+		Msg("subscriber is falling behind, waiting for it")
+
+	select {
+	case s.events <- event:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Close stops accepting events and waits for the subscribers to handle the pending ones
+func (b *Bus) Close() {
+
+	b.mu.Lock()
+	closing := !b.closed
+	b.closed = true
+	b.mu.Unlock()
+
+	if closing {
+		// the events being published are delivered before the buffers are closed
+		b.publishing.Wait()
+
+		b.mu.Lock()
+		for _, s := range b.subscribers {
+			close(s.events)
+		}
+		b.mu.Unlock()
+	}
+
+	b.running.Wait()
+}
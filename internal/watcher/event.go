@@ -0,0 +1,163 @@
+package watcher
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EventType identifies an event, matching its handler name on the configuration file
+// (under 'watcher.events'). Adding an event type requires its handler to be resolved
+// by Watcher.handlerFor and, optionally, a mail section generator on the Notifier.
+type EventType string
+
+const (
+	// EventChange is published when a change of the address (prefix or set) is committed
+	EventChange EventType = "on_change"
+	// EventMatch is published when the address did not change
+	EventMatch EventType = "on_match"
+	// EventError is published when an error occurs, Event.Err holds the error
+	EventError EventType = "on_error"
+	// EventNat is published when the WAN side is detected behind a NAT, Event.Nat holds the details
+	EventNat EventType = "on_nat"
+	// EventFlapping is published when the address starts flapping, Event.Flapping holds the details
+	EventFlapping EventType = "on_flapping"
+	// EventStart is published when the watcher starts
+	EventStart EventType = "on_start"
+	// EventStop is published when the watcher stops
+	EventStop EventType = "on_stop"
+	// EventRecover is published when checks succeed again after failing, Event.Outage holds the details
+	EventRecover EventType = "on_recover"
+	// EventFirstSeen is published when an address is seen with no previous address recorded
+	EventFirstSeen EventType = "on_first_seen"
+	// EventDowntime is published on startup when a previous run was found, Event.Downtime holds the gap
+	EventDowntime EventType = "on_downtime"
+	// EventPreChange is published ahead of a predicted change, Event.Prediction holds the predicted window
+	EventPreChange EventType = "on_pre_change"
+)
+
+// Event is published on the Bus whenever something happens to the watched address,
+// and is what the notifier and executor act upon. Fields that only apply to some
+// events, or some watcher modes, are nil when not applicable.
+type Event struct {
+	// ID of the record of the event, to which the outcomes of its handling refer, 0 if the
+	// event is not handled
+	ID uint64 `json:"id,omitempty"`
+	// Type of the event
+	Type EventType `json:"type"`
+	// Version of the watched address (v4|v6)
+	Version string `json:"version"`
+	// Timestamp is the moment the event happened
+	Timestamp time.Time `json:"timestamp"`
+
+	// Previous is the previously committed address, or the comma separated (without spaces)
+	// address set in set mode, only set on on_change
+	Previous string `json:"previous,omitempty"`
+	// Current is the observed address, or the comma separated (without spaces) address set in set mode
+	Current string `json:"current,omitempty"`
+	// Source is the url of the source the address was obtained from
+	Source string `json:"source,omitempty"`
+
+	// Err is the error that caused an on_error event, nil otherwise
+	Err error `json:"-"`
+
+	// Prefix holds the tracked IPv6 prefixes on on_change, nil if not tracking prefixes
+	Prefix *PrefixChange `json:"prefix,omitempty"`
+	// Hosts are the LAN host addresses derived from the current prefix on on_change,
+	// nil if no hosts are defined
+	Hosts []DerivedHost `json:"hosts,omitempty"`
+	// Set holds the address set differences on on_change, nil if not in set mode
+	Set *SetChange `json:"set,omitempty"`
+	// Nat holds the detected NAT status on on_nat, nil otherwise
+	Nat *NatDetails `json:"nat,omitempty"`
+	// Flapping holds the flapping details on on_flapping, nil otherwise
+	Flapping *FlapDetails `json:"flapping,omitempty"`
+	// Outage holds the details of the outage on on_recover, nil otherwise
+	Outage *OutageDetails `json:"outage,omitempty"`
+	// Downtime holds the gap since the previous run on on_downtime, nil otherwise
+	Downtime *DowntimeDetails `json:"downtime,omitempty"`
+	// Prediction holds the predicted change window on on_pre_change, nil otherwise
+	Prediction *Prediction `json:"prediction,omitempty"`
+
+	// AfterDowntime is set on on_change when the change happened while the watcher was down,
+	// being detected by the first check after a restart
+	AfterDowntime bool `json:"after_downtime,omitempty"`
+}
+
+// PrefixChange holds the tracked IPv6 prefixes, in CIDR notation, of an on_change event
+type PrefixChange struct {
+	// Previous is the previously committed prefix
+	Previous string `json:"previous"`
+	// Current is the newly committed prefix
+	Current string `json:"current"`
+}
+
+// SetChange holds the differences between two address sets of an on_change event
+type SetChange struct {
+	// Added are the addresses that joined the set
+	Added []string `json:"added"`
+	// Removed are the addresses that left the set
+	Removed []string `json:"removed"`
+}
+
+// NatDetails holds the NAT status of an on_nat event
+type NatDetails struct {
+	// Status is one of NatPresent, NatDouble or NatCarrierGrade
+	Status string `json:"status"`
+	// WanAddress is the WAN side address the observed address was compared against
+	WanAddress string `json:"wan_address"`
+}
+
+// FlapDetails holds the details of an on_flapping event
+type FlapDetails struct {
+	// Changes is the number of changes observed within 'watcher.flapping.window'
+	Changes int `json:"changes"`
+}
+
+// OutageDetails holds the details of the outage an on_recover event recovers from
+type OutageDetails struct {
+	// Since is the moment of the first failed check
+	Since time.Time `json:"since"`
+	// Duration is the time between the first failed check and the recovery, the time the
+	// watcher was down excluded
+	Duration time.Duration `json:"duration"`
+	// Failures is the number of consecutive failed checks
+	Failures int `json:"failures"`
+}
+
+// DowntimeDetails holds the gap between the previous run and the current one of an on_downtime event
+type DowntimeDetails struct {
+	// Since is the moment of the latest check of the previous run
+	Since time.Time `json:"since"`
+	// Duration is the time between the latest check of the previous run and the startup
+	Duration time.Duration `json:"duration"`
+	// Graceful reports whether the previous run was stopped gracefully, rather than killed or crashed
+	Graceful bool `json:"graceful"`
+}
+
+// NewEvent creates an event of the given type for the version, timestamped now
+func NewEvent(eventType EventType, version string) Event {
+	return Event{
+		Type:      eventType,
+		Version:   version,
+		Timestamp: time.Now(),
+	}
+}
+
+// ErrorMessage returns the message of Err, or an empty string if the event carries no error
+func (e Event) ErrorMessage() string {
+	if e.Err == nil {
+		return ""
+	}
+	return e.Err.Error()
+}
+
+// MarshalJSON encodes the event, with the error as its message
+func (e Event) MarshalJSON() ([]byte, error) {
+
+	type event Event // avoids recursing into MarshalJSON
+
+	return json.Marshal(struct {
+		event
+		Error string `json:"error,omitempty"`
+	}{event(e), e.ErrorMessage()})
+}
@@ -2,40 +2,60 @@ package watcher
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gweebg/ipwatcher/internal/config"
+	"github.com/gweebg/ipwatcher/internal/database"
 	"github.com/rs/zerolog"
 )
 
+// actionOutputLimit is the number of bytes of the stdout and stderr of an action that are
+// recorded, the remaining output being truncated
+const actionOutputLimit = 4096
+
 // Executor is used to execute actions when an event is triggered.
-// Needs an error channel to be passed, to be able to indicate when
-// errors occur while executing the actions.
+// Needs an error reporting function to be passed, to be able to indicate
+// when errors occur while executing the actions.
 type Executor struct {
-	Timeout   time.Duration
-	logger    zerolog.Logger
-	errorChan chan error
+	Timeout time.Duration
+	logger  zerolog.Logger
+	report  func(error)
+
+	// store records the runs of the actions
+	store database.Store
+
+	// running tracks the actions being executed, waited for on Shutdown
+	running   sync.WaitGroup
+	processMu sync.Mutex
+	processes map[*exec.Cmd]struct{}
+	closed    bool
 }
 
-// NewExecutor creates a config.Exec executor.
+// NewExecutor creates a config.Exec executor, recording the runs of the actions on store.
 //
 // Usage of a watcher.Executor
 //
-//		   ex := NewExecutor(errorChannel)
+//		   ex := NewExecutor(reportError, store)
 //		   action = config.Exec{
 //		       Type: "python",
 //	        Args: "",
 //			   Path: "script.py",
 //		   }
-//		   ex.Execute(action)
+//		   ex.ExecuteSlice([]config.ExecuteAction{action}, event)
 //
 // Each execution is associated with a context.ContextWithTimeout delimiting
 // the maximum time the action has to execute, defined on the configuration file.
-func NewExecutor(errorChan chan error) *Executor {
+func NewExecutor(report func(error), store database.Store) *Executor {
 
 	c := config.GetConfig()
 
@@ -47,25 +67,78 @@ func NewExecutor(errorChan chan error) *Executor {
 	return &Executor{
 		logger:    GetLogger().With().Str("service", "executor").Logger(),
 		Timeout:   time.Duration(timeout) * time.Second,
-		errorChan: errorChan,
+		report:    report,
+		store:     store,
+		processes: make(map[*exec.Cmd]struct{}),
 	}
 }
 
-// ExecuteSlice executes, in parallel, a slice of config.Exec actions.
-func (e *Executor) ExecuteSlice(actions []config.ExecuteAction) {
+// ExecuteSlice executes, in parallel, a slice of config.Exec actions. Each action is tracked
+// before its goroutine is started, so that Shutdown waits for every dispatched action.
+func (e *Executor) ExecuteSlice(actions []config.ExecuteAction, event Event) {
 	for _, action := range actions {
+
+		if !e.track() {
+			e.logger.Warn().Str("command", action.String()).Msg("executor is shutting down, skipping action")
+			continue
+		}
+
 		e.logger.Debug().Str("command", action.String()).Msg("executing action")
-		go e.Execute(action)
+		go e.execute(action, event)
 	}
 }
 
-// Execute executes the given config.Exec action defined on the configuration
+// execute executes the given config.Exec action defined on the configuration
 // file under 'events.<event>.actions'. Runs the action with a timed out context.Context
 // killing the process if a configuration file defined threshold (in seconds) is crossed,
-// limiting the execution time of the action.
-func (e *Executor) Execute(action config.ExecuteAction) {
+// limiting the execution time of the action. The templates of the action are rendered with
+// the event, whose data is also passed to the action as environment variables, and as JSON
+// on its standard input if the action sets 'stdin'. The action must have been tracked
+// beforehand. The run is recorded, with its exit code and captured output, if the event was.
+func (e *Executor) execute(action config.ExecuteAction, event Event) {
+
+	defer e.running.Done()
+
+	run := database.ActionRun{
+		EventID:  event.ID,
+		Command:  action.String(),
+		Start:    time.Now(),
+		ExitCode: -1,
+	}
+	stdoutCapture := &capture{limit: actionOutputLimit}
+	stderrCapture := &capture{limit: actionOutputLimit}
+
+	var runErr error
+	defer func() {
+		run.End = time.Now()
+		run.Stdout, run.Stderr = stdoutCapture.String(), stderrCapture.String()
+		if runErr != nil {
+			run.Error = runErr.Error()
+		}
+		e.record(event, run)
+	}()
+
+	action, err := action.Render(actionData(event))
+	if err != nil {
+		runErr = err
+		e.report(errors.Join(err, ErrorExecutor))
+		return
+	}
+	run.Command = action.String()
 
 	cmd, ctx, cancel := action.Command(e.Timeout)
+	cmd.Env = append(append(os.Environ(), actionEnv(event)...), action.Env...)
+
+	if action.Stdin {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			runErr = err
+			e.report(errors.Join(err, ErrorExecutor))
+			return
+		}
+		cmd.Stdin = bytes.NewReader(payload)
+	}
+
 	if cancel != nil && ctx != nil {
 		log.Println("with timeout!!!")
 		defer cancel()
@@ -81,9 +154,12 @@ func (e *Executor) Execute(action config.ExecuteAction) {
 	}
 
 	stdout, _ := cmd.StdoutPipe()
+	stdoutDone := make(chan struct{})
 	go func() {
+		defer close(stdoutDone)
 		scanner := bufio.NewScanner(stdout)
 		for scanner.Scan() {
+			stdoutCapture.WriteLine(scanner.Text())
 			e.logger.Debug().Str("command", action.String()).Msg(scanner.Text())
 		}
 	}()
@@ -91,20 +167,210 @@ func (e *Executor) Execute(action config.ExecuteAction) {
 	// redirecting the stderr of the spawned process to the pipe for later logging
 	stderr, _ := cmd.StderrPipe()
 	if err := cmd.Start(); err != nil {
-		e.errorChan <- errors.Join(err, ErrorExecutor)
+		runErr = err
+		e.fail(action, event, err)
 		return
 	}
 
+	e.register(cmd)
+	defer e.unregister(cmd)
+
 	scanner := bufio.NewScanner(stderr)
 	for scanner.Scan() {
-		e.errorChan <- errors.Join(errors.New(scanner.Text()), ErrorExecutor)
+		stderrCapture.WriteLine(scanner.Text())
+		e.fail(action, event, errors.New(scanner.Text()))
 	}
+	<-stdoutDone // the pipes must be read before waiting
 
-	err := cmd.Wait()
+	err = cmd.Wait()
+	run.ExitCode = cmd.ProcessState.ExitCode()
+	run.TimedOut = ctx != nil && errors.Is(ctx.Err(), context.DeadlineExceeded)
 	if err != nil {
-		e.errorChan <- errors.Join(err, ErrorExecutor)
+		runErr = err
+		e.fail(action, event, err)
 		return
 	}
 
 	e.logger.Debug().Str("command", action.String()).Msg("finished executing")
 }
+
+// fail reports err, raised by action while handling event. The failures of the on_error
+// actions are only logged, as reporting them would raise on_error again, looping forever.
+func (e *Executor) fail(action config.ExecuteAction, event Event, err error) {
+
+	if event.Type == EventError {
+		e.logger.Error().Str("command", action.String()).Err(err).Msg("on_error action failed")
+		return
+	}
+
+	e.report(errors.Join(err, ErrorExecutor))
+}
+
+// record stores the run of an action, unless the event it ran on was not recorded. As
+// with fail, the runs of on_error actions that cannot be stored are only logged.
+func (e *Executor) record(event Event, run database.ActionRun) {
+
+	if e.store == nil || run.EventID == 0 {
+		return
+	}
+
+	_, err := e.store.CreateActionRun(run)
+	switch {
+	case err == nil:
+	case event.Type == EventError:
+		e.logger.Error().Str("command", run.Command).Err(err).Msg("could not record the on_error action run")
+	default:
+		e.report(errors.Join(err, ErrorDatabase))
+	}
+}
+
+// Shutdown stops accepting new actions and gives the running ones, on_stop's included,
+// the grace period to finish, killing the ones still running afterward.
+func (e *Executor) Shutdown(grace time.Duration) {
+
+	e.processMu.Lock()
+	e.closed = true
+	e.processMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		e.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-time.After(grace):
+	}
+
+	e.processMu.Lock()
+	for cmd := range e.processes {
+		e.logger.Warn().Str("command", cmd.String()).Msgf("action did not finish within %v, killing it", grace)
+		_ = cmd.Process.Kill()
+	}
+	e.processMu.Unlock()
+
+	<-done
+}
+
+// track registers a new running action, returns false if the executor is shutting down
+func (e *Executor) track() bool {
+
+	e.processMu.Lock()
+	defer e.processMu.Unlock()
+
+	if e.closed {
+		return false
+	}
+
+	e.running.Add(1)
+	return true
+}
+
+func (e *Executor) register(cmd *exec.Cmd) {
+	e.processMu.Lock()
+	e.processes[cmd] = struct{}{}
+	e.processMu.Unlock()
+}
+
+func (e *Executor) unregister(cmd *exec.Cmd) {
+	e.processMu.Lock()
+	delete(e.processes, cmd)
+	e.processMu.Unlock()
+}
+
+// capture keeps the lines written to it up to limit bytes, marking the output as truncated
+// past it. Safe for concurrent use.
+type capture struct {
+	mu        sync.Mutex
+	limit     int
+	output    strings.Builder
+	truncated bool
+}
+
+// WriteLine appends line to the captured output, if within the limit
+func (c *capture) WriteLine(line string) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.truncated {
+		return
+	}
+
+	if c.output.Len()+len(line)+1 > c.limit {
+		c.output.WriteString(line[:max(c.limit-c.output.Len(), 0)])
+		c.truncated = true
+		return
+	}
+
+	c.output.WriteString(line)
+	c.output.WriteByte('\n')
+}
+
+// String returns the captured output, ending with a note if it was truncated
+func (c *capture) String() string {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.truncated {
+		return c.output.String() + "\n[truncated]"
+	}
+	return c.output.String()
+}
+
+// actionData returns the data the templates of the actions are rendered with for event
+func actionData(event Event) config.ActionData {
+
+	data := config.ActionData{
+		Event:     string(event.Type),
+		Version:   event.Version,
+		Current:   event.Current,
+		Previous:  event.Previous,
+		Source:    event.Source,
+		Error:     event.ErrorMessage(),
+		Timestamp: event.Timestamp,
+	}
+
+	if event.Prefix != nil {
+		data.Prefix = event.Prefix.Current
+	}
+
+	if event.Hosts != nil {
+		data.Hosts = make(map[string]string, len(event.Hosts))
+		for _, host := range event.Hosts {
+			data.Hosts[host.Name] = host.Address
+		}
+	}
+
+	return data
+}
+
+// actionEnv returns the environment variables describing the event, the ones that do not
+// apply to it being set empty
+func actionEnv(event Event) []string {
+
+	env := []string{
+		"IPWATCHER_EVENT=" + string(event.Type),
+		"IPWATCHER_VERSION=" + event.Version,
+		"IPWATCHER_CURRENT_ADDRESS=" + event.Current,
+		"IPWATCHER_PREVIOUS_ADDRESS=" + event.Previous,
+		"IPWATCHER_SOURCE=" + event.Source,
+		"IPWATCHER_ERROR=" + event.ErrorMessage(),
+	}
+
+	if event.Set != nil {
+		env = append(env,
+			"IPWATCHER_ADDED="+strings.Join(event.Set.Added, " "),
+			"IPWATCHER_REMOVED="+strings.Join(event.Set.Removed, " "),
+		)
+	}
+
+	if event.Hosts != nil {
+		env = append(env, hostsEnv(event.Hosts)...)
+	}
+
+	return env
+}
@@ -0,0 +1,50 @@
+package watcher
+
+import (
+	"os/exec"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/config"
+	"github.com/rs/zerolog"
+)
+
+func TestExecutorReportsFailures(t *testing.T) {
+
+	if _, err := exec.LookPath("ls"); err != nil {
+		t.Skip("'ls' is not available")
+	}
+
+	// writes to stderr and exits with an error
+	failing := config.ExecuteAction{Type: "execute", Bin: "ls", Args: "/does/not/exist", TTL: -1}
+
+	cases := []struct {
+		event    EventType
+		reported bool
+	}{
+		{EventChange, true},
+		// reporting would raise on_error again
+		{EventError, false},
+	}
+
+	for _, c := range cases {
+		t.Run(string(c.event), func(t *testing.T) {
+
+			var reports atomic.Int32
+			e := &Executor{
+				Timeout:   time.Minute,
+				logger:    zerolog.Nop(),
+				report:    func(error) { reports.Add(1) },
+				processes: make(map[*exec.Cmd]struct{}),
+			}
+
+			e.ExecuteSlice([]config.ExecuteAction{failing}, NewEvent(c.event, "v4"))
+			e.Shutdown(time.Minute)
+
+			if reported := reports.Load() > 0; reported != c.reported {
+				t.Fatalf("expected the failure to be reported: %v, got %d reports", c.reported, reports.Load())
+			}
+		})
+	}
+}
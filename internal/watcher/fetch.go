@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/rs/zerolog"
 	"io"
 	"net"
@@ -14,11 +15,15 @@ import (
 )
 
 type Fetcher struct {
+	// health is the health of each source, persisted across restarts
+	health *sourceHealth
+
 	logger zerolog.Logger
 }
 
 func NewFetcher() *Fetcher {
 	return &Fetcher{
+		health: newSourceHealth(),
 		logger: GetLogger().With().Str("service", "fetcher").Logger(),
 	}
 }
@@ -48,6 +53,7 @@ func (f *Fetcher) RequestAddress(version string) (string, string, error) {
 		response, err := sendRequest(url)
 		if err != nil {
 			f.logger.Error().Err(err).Str("source_name", source.Name).Msg("failed to send request to source")
+			f.health.failed(source.Name, err)
 			continue
 		}
 
@@ -56,9 +62,12 @@ func (f *Fetcher) RequestAddress(version string) (string, string, error) {
 		valid := net.ParseIP(address)
 		if valid == nil {
 			f.logger.Error().Err(err).Str("source_name", source.Name).Msgf("source did not return a valid IP address: '%v', skipping", address)
+			f.health.failed(source.Name, fmt.Errorf("invalid IP address: '%v'", address))
 			continue
 		}
 
+		f.health.succeeded(source.Name)
+
 		fromSource = url
 		f.logger.Debug().Str("source", url).Msgf("valid address from source '%v'", source.Name)
 
@@ -75,6 +84,29 @@ func (f *Fetcher) RequestAddress(version string) (string, string, error) {
 	return address, fromSource, nil
 }
 
+// RequestFrom queries a single config.Source for an address of the given version,
+// used when the source is not part of the 'sources' fall-back list (e.g. a router).
+func (f *Fetcher) RequestFrom(source config.Source, version string) (string, error) {
+
+	url, err := source.Url.GetUrl(version)
+	if err != nil {
+		return "", err
+	}
+
+	response, err := sendRequest(url)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	address := strings.TrimSpace(f.parseResponse(response, source))
+	if net.ParseIP(address) == nil {
+		return "", fmt.Errorf("source '%v' did not return a valid IP address: '%v'", source.Name, address)
+	}
+
+	return address, nil
+}
+
 func (f *Fetcher) parseResponse(response *http.Response, source config.Source) string {
 
 	// check if http response status code is 'positive' (200<=status<300)
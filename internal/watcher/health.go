@@ -0,0 +1,77 @@
+package watcher
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// SourceHealth is the health of a source, as observed by the Fetcher
+type SourceHealth struct {
+	// Failures is the number of consecutive failed requests to the source
+	Failures int `json:"failures"`
+	// LastSuccess is the moment of the latest valid address returned by the source
+	LastSuccess time.Time `json:"last_success"`
+	// LastError is the error of the latest failed request, empty if the latest request succeeded
+	LastError string `json:"last_error,omitempty"`
+}
+
+// sourceHealth keeps the SourceHealth of each source, by source name
+type sourceHealth struct {
+	mu      sync.Mutex
+	sources map[string]SourceHealth
+}
+
+func newSourceHealth() *sourceHealth {
+	return &sourceHealth{sources: map[string]SourceHealth{}}
+}
+
+// succeeded records a valid address returned by the source
+func (h *sourceHealth) succeeded(name string) {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sources[name] = SourceHealth{LastSuccess: time.Now()}
+}
+
+// failed records a failed request to the source
+func (h *sourceHealth) failed(name string, err error) {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	health := h.sources[name]
+	health.Failures++
+	health.LastError = err.Error()
+	h.sources[name] = health
+}
+
+// encode returns the health of the sources as JSON, to be persisted
+func (h *sourceHealth) encode() (string, error) {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	encoded, err := json.Marshal(h.sources)
+	return string(encoded), err
+}
+
+// restore replaces the health of the sources with the persisted encoded one
+func (h *sourceHealth) restore(encoded string) error {
+
+	if encoded == "" {
+		return nil
+	}
+
+	sources := map[string]SourceHealth{}
+	if err := json.Unmarshal([]byte(encoded), &sources); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sources = sources
+	return nil
+}
@@ -0,0 +1,34 @@
+package watcher
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseSince parses the start of a queried period, either as a duration before now
+// (e.g. '24h', or '7d' in days) or as an RFC 3339 timestamp. An empty value returns
+// the zero time, covering the whole history.
+func ParseSince(value string, now time.Time) (time.Time, error) {
+
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if days, found := strings.CutSuffix(value, "d"); found {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(-duration), nil
+	}
+
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, nil
+	}
+
+	return time.Time{}, fmt.Errorf("'%v' is neither a duration (e.g. '24h', '7d') nor an RFC 3339 timestamp", value)
+}
@@ -0,0 +1,86 @@
+package watcher
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/gweebg/ipwatcher/internal/config"
+)
+
+// DerivedHost is a LAN host alongside its address derived from the current prefix
+type DerivedHost struct {
+	// Name of the host, as defined under 'watcher.v6.hosts'
+	Name string `json:"name"`
+	// Address is the prefix combined with the interface identifier of the host
+	Address string `json:"address"`
+}
+
+// deriveHosts computes the address of each host by combining the network bits of
+// prefix with the interface identifier (suffix) of the host.
+func deriveHosts(prefix string, hosts []config.Host) ([]DerivedHost, error) {
+
+	network, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	derived := make([]DerivedHost, 0, len(hosts))
+	for _, host := range hosts {
+
+		suffix, err := netip.ParseAddr(host.Suffix)
+		if err != nil {
+			return nil, err
+		}
+
+		derived = append(derived, DerivedHost{
+			Name:    host.Name,
+			Address: deriveAddress(network, suffix).String(),
+		})
+	}
+
+	return derived, nil
+}
+
+// deriveAddress keeps the first prefix.Bits() bits of the prefix and the remaining bits of suffix
+func deriveAddress(prefix netip.Prefix, suffix netip.Addr) netip.Addr {
+
+	network := prefix.Masked().Addr().As16()
+	identifier := suffix.As16()
+
+	bits := prefix.Bits()
+	for i := range network {
+
+		// number of bits of this byte that belong to the network part
+		networkBits := min(max(bits-i*8, 0), 8)
+		mask := byte(0xff << (8 - networkBits))
+
+		network[i] = network[i]&mask | identifier[i]&^mask
+	}
+
+	return netip.AddrFrom16(network)
+}
+
+// hostsEnv formats the derived hosts as environment variables, a IPWATCHER_HOSTS variable
+// with every 'name=address' pair and a IPWATCHER_HOST_<NAME> variable for each host.
+func hostsEnv(hosts []DerivedHost) []string {
+
+	pairs := make([]string, 0, len(hosts))
+	env := make([]string, 0, len(hosts)+1)
+
+	for _, host := range hosts {
+		pairs = append(pairs, host.Name+"="+host.Address)
+		env = append(env, "IPWATCHER_HOST_"+envName(host.Name)+"="+host.Address)
+	}
+
+	return append(env, "IPWATCHER_HOSTS="+strings.Join(pairs, " "))
+}
+
+// envName converts name into a valid environment variable name, e.g. 'nas.lan' to 'NAS_LAN'
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(name))
+}
@@ -0,0 +1,53 @@
+package watcher
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/gweebg/ipwatcher/internal/config"
+)
+
+func TestDeriveAddress(t *testing.T) {
+
+	cases := []struct {
+		prefix   string
+		suffix   string
+		expected string
+	}{
+		{"2001:db8:1234:5600::/56", "::1:2:3:4", "2001:db8:1234:5600:1:2:3:4"},
+		{"2001:db8:1234:5678::/64", "::ff:fe00:1", "2001:db8:1234:5678:0:ff:fe00:1"},
+		// the prefix ends within a byte, its low nibble is taken from the suffix
+		{"2001:db8:1234:56f0::/60", "::abcd:1:2:3:4", "2001:db8:1234:56fd:1:2:3:4"},
+		// the host bits of the prefix are ignored
+		{"2001:db8:1234:5678::99/64", "::1", "2001:db8:1234:5678::1"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.prefix, func(t *testing.T) {
+
+			derived := deriveAddress(netip.MustParsePrefix(c.prefix), netip.MustParseAddr(c.suffix))
+			if derived.String() != c.expected {
+				t.Fatalf("expected %v, got %v", c.expected, derived)
+			}
+		})
+	}
+}
+
+func TestDeriveHosts(t *testing.T) {
+
+	hosts := []config.Host{{Name: "nas", Suffix: "::10"}, {Name: "printer", Suffix: "::20"}}
+
+	derived, err := deriveHosts("2001:db8:1234:5678::/64", hosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(derived) != 2 || derived[0].Name != "nas" || derived[0].Address != "2001:db8:1234:5678::10" ||
+		derived[1].Name != "printer" || derived[1].Address != "2001:db8:1234:5678::20" {
+		t.Fatalf("unexpected derived hosts %+v", derived)
+	}
+
+	if _, err = deriveHosts("2001:db8:1234:5678::/64", []config.Host{{Name: "nas", Suffix: "nas"}}); err == nil {
+		t.Fatal("expected an invalid suffix to fail")
+	}
+}
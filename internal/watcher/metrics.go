@@ -0,0 +1,60 @@
+package watcher
+
+import (
+	"sync"
+	"time"
+)
+
+// Metrics counts the events published on the bus
+type Metrics struct {
+	mu sync.Mutex
+
+	// StartedAt is the moment the watcher started
+	StartedAt time.Time `json:"started_at"`
+	// Events is the number of events handled per type
+	Events map[EventType]uint64 `json:"events"`
+	// LastEvent is the moment of the latest event per type
+	LastEvent map[EventType]time.Time `json:"last_event"`
+}
+
+// NewMetrics creates an empty Metrics, starting now
+func NewMetrics() *Metrics {
+	return &Metrics{
+		StartedAt: time.Now(),
+		Events:    make(map[EventType]uint64),
+		LastEvent: make(map[EventType]time.Time),
+	}
+}
+
+// Record accounts for event, subscribed to the bus
+func (m *Metrics) Record(event Event) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Events[event.Type]++
+	m.LastEvent[event.Type] = event.Timestamp
+}
+
+// Snapshot returns a copy of the current metrics
+func (m *Metrics) Snapshot() *Metrics {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := &Metrics{
+		StartedAt: m.StartedAt,
+		Events:    make(map[EventType]uint64, len(m.Events)),
+		LastEvent: make(map[EventType]time.Time, len(m.LastEvent)),
+	}
+
+	for eventType, count := range m.Events {
+		snapshot.Events[eventType] = count
+	}
+
+	for eventType, at := range m.LastEvent {
+		snapshot.LastEvent[eventType] = at
+	}
+
+	return snapshot
+}
@@ -0,0 +1,124 @@
+package watcher
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/gweebg/ipwatcher/internal/config"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// NatNone indicates that the WAN address matches the externally observed address
+	NatNone = "none"
+	// NatPresent indicates that the public WAN address differs from the observed address
+	NatPresent = "nat"
+	// NatDouble indicates that the WAN address is a private address, behind another NAT
+	NatDouble = "double_nat"
+	// NatCarrierGrade indicates that the WAN address belongs to the shared address space (RFC 6598)
+	NatCarrierGrade = "cgnat"
+)
+
+// sharedAddressSpace is the 100.64.0.0/10 range reserved for carrier-grade NAT
+var sharedAddressSpace = &net.IPNet{
+	IP:   net.IPv4(100, 64, 0, 0),
+	Mask: net.CIDRMask(10, 32),
+}
+
+// NatDetector compares the WAN side address, either reported by the router or
+// read from a local interface, with the externally observed address.
+type NatDetector struct {
+	settings config.Nat
+	fetcher  *Fetcher
+	logger   zerolog.Logger
+}
+
+// NewNatDetector creates a NatDetector from the 'watcher.nat' configuration,
+// returns nil if NAT detection is not configured.
+func NewNatDetector(fetcher *Fetcher) *NatDetector {
+
+	c := config.GetConfig()
+
+	settings, _ := c.Get("watcher.nat").(*config.Nat)
+	if settings == nil {
+		return nil
+	}
+
+	return &NatDetector{
+		settings: *settings,
+		fetcher:  fetcher,
+		logger:   GetLogger().With().Str("service", "nat").Logger(),
+	}
+}
+
+// Detect returns the NAT status for the observed address alongside the WAN address it
+// was compared against.
+func (n *NatDetector) Detect(observed string, version string) (string, string, error) {
+
+	wan, err := n.wanAddress(version)
+	if err != nil {
+		return "", "", err
+	}
+
+	return natStatus(wan, observed), wan, nil
+}
+
+func (n *NatDetector) wanAddress(version string) (string, error) {
+
+	if n.settings.Router != nil {
+		return n.fetcher.RequestFrom(*n.settings.Router, version)
+	}
+
+	return interfaceAddress(n.settings.Interface, version)
+}
+
+// natStatus classifies the relation between the WAN address and the observed address
+func natStatus(wan string, observed string) string {
+
+	wanIp := net.ParseIP(wan)
+	observedIp := net.ParseIP(observed)
+
+	if wanIp.Equal(observedIp) {
+		return NatNone
+	}
+
+	if sharedAddressSpace.Contains(wanIp) {
+		return NatCarrierGrade
+	}
+
+	if wanIp.IsPrivate() {
+		return NatDouble
+	}
+
+	return NatPresent
+}
+
+// interfaceAddress returns the first global unicast address of the given version
+// assigned to the interface named name.
+func interfaceAddress(name string, version string) (string, error) {
+
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", err
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", err
+	}
+
+	for _, addr := range addrs {
+
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || !ipNet.IP.IsGlobalUnicast() {
+			continue
+		}
+
+		isV4 := ipNet.IP.To4() != nil
+		if (version == "v4" && isV4) || (version == "v6" && !isV4) {
+			return ipNet.IP.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("interface '%v' has no global IP%v address", name, version)
+}
@@ -0,0 +1,70 @@
+//go:build linux
+
+package watcher
+
+import (
+	"errors"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchNetwork subscribes to the netlink route and address change notifications,
+// calling notify whenever a link, address or route changes, until quit is closed.
+func watchNetwork(quit <-chan struct{}, notify func()) error {
+
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	groups := unix.RTMGRP_LINK |
+		unix.RTMGRP_IPV4_IFADDR | unix.RTMGRP_IPV6_IFADDR |
+		unix.RTMGRP_IPV4_ROUTE | unix.RTMGRP_IPV6_ROUTE
+
+	err = unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: uint32(groups)})
+	if err != nil {
+		return err
+	}
+
+	// wake up every second to check if the watcher was stopped
+	timeout := unix.NsecToTimeval(time.Second.Nanoseconds())
+	err = unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &timeout)
+	if err != nil {
+		return err
+	}
+
+	buffer := make([]byte, unix.Getpagesize())
+	for {
+
+		select {
+		case <-quit:
+			return nil
+		default:
+		}
+
+		n, _, err := unix.Recvfrom(fd, buffer, 0)
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		messages, err := syscall.ParseNetlinkMessage(buffer[:n])
+		if err != nil {
+			continue
+		}
+
+		for _, message := range messages {
+			switch message.Header.Type {
+			case unix.RTM_NEWLINK, unix.RTM_DELLINK,
+				unix.RTM_NEWADDR, unix.RTM_DELADDR,
+				unix.RTM_NEWROUTE, unix.RTM_DELROUTE:
+				notify()
+			}
+		}
+	}
+}
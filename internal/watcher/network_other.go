@@ -0,0 +1,12 @@
+//go:build !linux
+
+package watcher
+
+import (
+	"errors"
+)
+
+// watchNetwork is only supported on Linux, where netlink notifications are available
+func watchNetwork(quit <-chan struct{}, notify func()) error {
+	return errors.New("network change notifications are only supported on linux")
+}
@@ -1,11 +1,14 @@
 package watcher
 
 import (
-	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gweebg/ipwatcher/internal/config"
+	"github.com/gweebg/ipwatcher/internal/database"
 	"github.com/rs/zerolog"
 	"gopkg.in/gomail.v2"
 )
@@ -18,6 +21,10 @@ type Recipient struct {
 	Address string `mapstructure:"address"`
 }
 
+// maxHeldNotifications is the number of notifications held during quiet hours, past which
+// the oldest ones are dropped
+const maxHeldNotifications = 100
+
 // Notifier allows for email mass notification
 type Notifier struct {
 	// From is the address to send from, obtained from the configuration file at 'watcher.smtp.*'
@@ -28,12 +35,25 @@ type Notifier struct {
 	// emailDialer represents the *gomail.Dialer object responsible by sending the email messages
 	emailDialer *gomail.Dialer
 
+	// quietHours are the windows during which notifications are held, nil if not defined
+	quietHours *QuietHours
+
+	// store records the notification attempts
+	store database.Store
+
+	// held are the notifications waiting for the quiet window to end
+	held       []Event
+	heldMu     sync.Mutex
+	flushTimer *time.Timer
+
 	// doneCh is a channel that indicates when the email set is sent
 	doneCh chan struct{}
 	logger zerolog.Logger
 }
 
-func NewNotifier() *Notifier {
+// NewNotifier creates a Notifier from the 'watcher.smtp' settings, recording the
+// notification attempts on store
+func NewNotifier(store database.Store) *Notifier {
 
 	c := config.GetConfig()
 
@@ -56,32 +76,118 @@ func NewNotifier() *Notifier {
 		From:        c.GetString("watcher.smtp.from_address"),
 		Recipients:  recipients,
 		emailDialer: dialer,
+		quietHours:  NewQuietHours(),
+		store:       store,
 		logger:      logger,
 	}
 }
 
-func (n *Notifier) NotifyMail(ctx context.Context) error {
+// NotifyMail notifies every recipient of the event. During quiet hours the notification
+// is held instead, and delivered alongside every other held notification as a batch
+// when the window ends.
+func (n *Notifier) NotifyMail(event Event) error {
+
+	if n.quietHours != nil {
+		if quiet, end := n.quietHours.Active(time.Now()); quiet {
+			n.hold(event, end)
+			return nil
+		}
+	}
+
+	return n.send(event)
+}
+
+// hold queues event until the quiet window ends at end
+func (n *Notifier) hold(event Event, end time.Time) {
+
+	n.heldMu.Lock()
+	defer n.heldMu.Unlock()
+
+	if len(n.held) == maxHeldNotifications {
+		n.logger.Warn().Msgf("holding more than %d notifications, dropping the oldest", maxHeldNotifications)
+		n.held = n.held[1:]
+	}
+
+	n.held = append(n.held, event)
+	n.logger.Info().Time("until", end).Msgf("quiet hours, holding notification (%d held)", len(n.held))
+
+	if n.flushTimer == nil {
+		n.flushTimer = time.AfterFunc(time.Until(end), n.flush)
+	}
+}
+
+// Discard drops the held notifications, logging them, used when stopping the watcher
+// during quiet hours, which would otherwise end with every held notification at once.
+func (n *Notifier) Discard() {
+
+	n.heldMu.Lock()
+	held := n.held
+	if n.flushTimer != nil {
+		n.flushTimer.Stop()
+	}
+	n.held, n.flushTimer = nil, nil
+	n.heldMu.Unlock()
+
+	for _, event := range held {
+		n.logger.Warn().
+			Str("event", string(event.Type)).
+			Time("timestamp", event.Timestamp).
+			Msg("stopping during quiet hours, dropping held notification")
+	}
+}
+
+// flush delivers the held notifications as a single batch
+func (n *Notifier) flush() {
+
+	n.heldMu.Lock()
+	held := n.held
+	n.held, n.flushTimer = nil, nil
+	n.heldMu.Unlock()
+
+	if len(held) == 0 {
+		return
+	}
+
+	if err := n.send(held...); err != nil {
+		n.logger.Error().Err(err).Msgf("cannot deliver %d held notifications", len(held))
+		return
+	}
+
+	n.logger.Info().Msgf("delivered %d held notifications", len(held))
+}
+
+// send emails every recipient with one message containing the events, recording the
+// attempt for each event and recipient
+func (n *Notifier) send(events ...Event) error {
 
 	n.logger.Debug().Msg("dialing smtp server")
 
 	s, err := n.emailDialer.Dial()
 	if err != nil {
+		for _, r := range n.Recipients {
+			n.record(events, r, err)
+		}
 		return err
 	}
 
+	subject := "Update on your public address!"
+	if len(events) > 1 {
+		subject = fmt.Sprintf("%d updates on your public address!", len(events))
+	}
+
 	m := gomail.NewMessage()
 	for _, r := range n.Recipients {
 
-		ctx = context.WithValue(ctx, "name", r.Name)
-
 		m.SetHeader("From", n.From)
 		m.SetAddressHeader("To", r.Address, r.Name)
-		m.SetHeader("Subject", "Update on your public address!")
-		m.SetBody("text/html", generateMailBody(ctx))
+		m.SetHeader("Subject", subject)
+		m.SetBody("text/html", generateMailBody(r.Name, events...))
 
-		if err := gomail.Send(s, m); err != nil {
+		err := gomail.Send(s, m)
+		if err != nil {
 			n.logger.Error().Err(err).Msgf("cannot send email to '%s'", r.Address)
 		}
+		n.record(events, r, err)
 		m.Reset()
 	}
 
@@ -93,101 +199,317 @@ func (n *Notifier) NotifyMail(ctx context.Context) error {
 	return nil
 }
 
-type bodyGenerator func(context.Context) string
+// record stores the attempt to notify recipient of the events, failed if err is not nil,
+// skipping the events that were not recorded
+func (n *Notifier) record(events []Event, recipient Recipient, err error) {
 
-func generateMailBody(ctx context.Context) string {
+	if n.store == nil {
+		return
+	}
 
-	patterns := map[string]bodyGenerator{
-		"on_change": generateOnChange,
-		"on_match":  generateOnMatch,
-		"on_error":  generateOnError,
+	attempt := database.NotificationAttempt{
+		Timestamp: time.Now(),
+		Channel:   "email",
+		Recipient: recipient.Address,
+		Success:   err == nil,
+	}
+	if err != nil {
+		attempt.Error = err.Error()
 	}
 
-	event := ctx.Value("event").(string)
-	generator, _ := patterns[event]
+	for _, event := range events {
+		if event.ID == 0 {
+			continue
+		}
 
-	return generator(ctx)
+		attempt.EventID = event.ID
+		if _, err := n.store.CreateNotificationAttempt(attempt); err != nil {
+			n.logger.Error().Err(errors.Join(err, ErrorDatabase)).Msg("cannot record notification attempt")
+		}
+	}
 }
 
-func generateOnChange(ctx context.Context) string {
+// sectionGenerator generates the mail section of an event for the recipient named name
+type sectionGenerator func(name string, event Event) string
+
+// sectionGenerators maps each event type to its mail section, the ones without a
+// generator fall back to generateOnEvent.
+var sectionGenerators = map[EventType]sectionGenerator{
+	EventChange:    generateOnChange,
+	EventMatch:     generateOnMatch,
+	EventError:     generateOnError,
+	EventNat:       generateOnNat,
+	EventFlapping:  generateOnFlapping,
+	EventStart:     generateOnStart,
+	EventStop:      generateOnStop,
+	EventRecover:   generateOnRecover,
+	EventFirstSeen: generateOnFirstSeen,
+	EventDowntime:  generateOnDowntime,
+	EventPreChange: generateOnPreChange,
+}
 
-	name := ctx.Value("name").(string)
+// generateMailBody generates the email body for the recipient named name, one section
+// per event, more than one event being sent when delivering held notifications.
+func generateMailBody(name string, events ...Event) string {
 
-	previousAddress := ctx.Value("previous_address").(string)
-	currentAddress := ctx.Value("current_address").(string)
+	sections := make([]string, 0, len(events))
+	for _, event := range events {
 
-	timestamp := ctx.Value("timestamp").(time.Time)
-	source := ctx.Value("source").(string)
+		generator, ok := sectionGenerators[event.Type]
+		if !ok {
+			generator = generateOnEvent
+		}
+
+		sections = append(sections, generator(name, event))
+	}
 
-	// todo: make email template dynamic by allowing its definition on the configuration file
 	return fmt.Sprintf(`<html>
 	<head>
 		<title>Watcher Report</title>
 	</head>
 	<body style="font-family: Arial, sans-serif;">
-		<div style="background-color: #f0f0f0; padding: 20px;">
-			<h1 style="color: #333;">Watcher Update (Change)</h1>
-			<p style="font-size: 16px;">Hello <strong>%s</strong>, your public IP address has been changed. Here are the details:</p>
-			<ul style="font-size: 16px;">
-				<li><strong>Previous Address:</strong> %s</li>
-				<li><strong>Current Address:</strong> %s</li>
-				<li><strong>Updated at:</strong> %s</li>
-				<li><strong>Information Source:</strong> %s</li>
-			</ul>
-		</div>
+	%s
 	</body>
 	</html>`,
-		name, previousAddress, currentAddress, timestamp.Format("2006-01-02 15:04:05"), source)
+		strings.Join(sections, "\n\t"))
+}
+
+func generateOnChange(name string, event Event) string {
+
+	details := ""
+
+	// prefixes are only present when tracking IPv6 delegated prefixes
+	if event.Prefix != nil {
+		details += fmt.Sprintf(`
+			<li><strong>Previous Prefix:</strong> %s</li>
+			<li><strong>Current Prefix:</strong> %s</li>`, event.Prefix.Previous, event.Prefix.Current)
+	}
+
+	// added and removed addresses, only present in set mode
+	if event.Set != nil {
+		details += fmt.Sprintf(`
+			<li><strong>Added:</strong> %s</li>
+			<li><strong>Removed:</strong> %s</li>`, strings.Join(event.Set.Added, ", "), strings.Join(event.Set.Removed, ", "))
+	}
+
+	// derived LAN host addresses, only present when hosts are defined under 'watcher.v6.hosts'
+	for _, host := range event.Hosts {
+		details += fmt.Sprintf(`
+			<li><strong>%s:</strong> %s</li>`, host.Name, host.Address)
+	}
+
+	// the change happened while the watcher was down, the update time is when it was detected
+	if event.AfterDowntime {
+		details += `
+			<li><strong>Detected After Downtime:</strong> yes</li>`
+	}
+
+	// todo: make email template dynamic by allowing its definition on the configuration file
+	return fmt.Sprintf(`<div style="background-color: #f0f0f0; padding: 20px;">
+		<h1 style="color: #333;">Watcher Update (Change)</h1>
+		<p style="font-size: 16px;">Hello <strong>%s</strong>, your public IP address has been changed. Here are the details:</p>
+		<ul style="font-size: 16px;">
+			<li><strong>Previous Address:</strong> %s</li>
+			<li><strong>Current Address:</strong> %s</li>%s
+			<li><strong>Updated at:</strong> %s</li>
+			<li><strong>Information Source:</strong> %s</li>
+		</ul>
+	</div>`,
+		name, event.Previous, event.Current, details, event.Timestamp.Format("2006-01-02 15:04:05"), event.Source)
 
 }
 
-func generateOnMatch(ctx context.Context) string {
+func generateOnMatch(name string, event Event) string {
 
-	name := ctx.Value("name").(string)
-	timestamp := ctx.Value("timestamp").(time.Time)
-	source := ctx.Value("source").(string)
+	return fmt.Sprintf(`<div style="background-color: #f0f0f0; padding: 20px;">
+		<h1 style="color: #333;">Watcher Update (Match)</h1>
+		<p style="font-size: 16px;">Hello <strong>%s</strong>, your public IP address is still the same. Here are the details:</p>
+		<ul style="font-size: 16px;">
+			<li><strong>At:</strong> %s</li>
+			<li><strong>Information Source:</strong> %s</li>
+		</ul>
+	</div>`,
+		name, event.Timestamp.Format("2006-01-02 15:04:05"), event.Source)
 
-	return fmt.Sprintf(`<html>
-	<head>
-		<title>Watcher Report</title>
-	</head>
-	<body style="font-family: Arial, sans-serif;">
-		<div style="background-color: #f0f0f0; padding: 20px;">
-			<h1 style="color: #333;">Watcher Update (Match)</h1>
-			<p style="font-size: 16px;">Hello <strong>%s</strong>, your public IP address is still the same. Here are the details:</p>
-			<ul style="font-size: 16px;">
-				<li><strong>At:</strong> %s</li>
-				<li><strong>Information Source:</strong> %s</li>
-			</ul>
-		</div>
-	</body>
-	</html>`,
-		name, timestamp.Format("2006-01-02 15:04:05"), source)
+}
+
+func generateOnError(name string, event Event) string {
+
+	return fmt.Sprintf(`<div style="background-color: #f0f0f0; padding: 20px;">
+		<h1 style="color: #333;">Watcher Error</h1>
+		<p style="font-size: 16px;">Hello <strong>%s</strong>, an error occured while watching your address. Here are the details:</p>
+		<ul style="font-size: 16px;">
+			<li><strong>At:</strong> %s</li>
+			<li><strong>Error:</strong> %s</li>
+		</ul>
+	</div>`,
+		name, event.Timestamp.Format("2006-01-02 15:04:05"), event.ErrorMessage())
 
 }
 
-func generateOnError(ctx context.Context) string {
+func generateOnNat(name string, event Event) string {
 
-	name := ctx.Value("name").(string)
+	nat := NatDetails{}
+	if event.Nat != nil {
+		nat = *event.Nat
+	}
 
-	timestamp := ctx.Value("timestamp").(time.Time)
-	err := ctx.Value("error").(error)
+	return fmt.Sprintf(`<div style="background-color: #f0f0f0; padding: 20px;">
+		<h1 style="color: #333;">Watcher Update (NAT)</h1>
+		<p style="font-size: 16px;">Hello <strong>%s</strong>, your WAN address differs from your public IP address, inbound services may be unreachable. Here are the details:</p>
+		<ul style="font-size: 16px;">
+			<li><strong>NAT Status:</strong> %s</li>
+			<li><strong>WAN Address:</strong> %s</li>
+			<li><strong>Public Address:</strong> %s</li>
+			<li><strong>At:</strong> %s</li>
+			<li><strong>Information Source:</strong> %s</li>
+		</ul>
+	</div>`,
+		name, nat.Status, nat.WanAddress, event.Current, event.Timestamp.Format("2006-01-02 15:04:05"), event.Source)
 
-	return fmt.Sprintf(`<html>
-	<head>
-		<title>Watcher Report</title>
-	</head>
-	<body style="font-family: Arial, sans-serif;">
-		<div style="background-color: #f0f0f0; padding: 20px;">
-			<h1 style="color: #333;">Watcher Error</h1>
-			<p style="font-size: 16px;">Hello <strong>%s</strong>, an error occured while watching your address. Here are the details:</p>
-			<ul style="font-size: 16px;">
-				<li><strong>At:</strong> %s</li>
-				<li><strong>Error:</strong> %s</li>
-			</ul>
-		</div>
-	</body>
-	</html>`,
-		name, timestamp.Format("2006-01-02 15:04:05"), err.Error())
+}
+
+func generateOnFlapping(name string, event Event) string {
+
+	changes := 0
+	if event.Flapping != nil {
+		changes = event.Flapping.Changes
+	}
+
+	return fmt.Sprintf(`<div style="background-color: #f0f0f0; padding: 20px;">
+		<h1 style="color: #333;">Watcher Update (Flapping)</h1>
+		<p style="font-size: 16px;">Hello <strong>%s</strong>, your public IP address is flapping, changes will not be handled until it stabilizes. Here are the details:</p>
+		<ul style="font-size: 16px;">
+			<li><strong>Observed Address:</strong> %s</li>
+			<li><strong>Recent Changes:</strong> %d</li>
+			<li><strong>At:</strong> %s</li>
+			<li><strong>Information Source:</strong> %s</li>
+		</ul>
+	</div>`,
+		name, event.Current, changes, event.Timestamp.Format("2006-01-02 15:04:05"), event.Source)
+
+}
+
+func generateOnStart(name string, event Event) string {
+
+	return fmt.Sprintf(`<div style="background-color: #f0f0f0; padding: 20px;">
+		<h1 style="color: #333;">Watcher Started</h1>
+		<p style="font-size: 16px;">Hello <strong>%s</strong>, the watcher is now watching your IP%s address. Here are the details:</p>
+		<ul style="font-size: 16px;">
+			<li><strong>At:</strong> %s</li>
+		</ul>
+	</div>`,
+		name, event.Version, event.Timestamp.Format("2006-01-02 15:04:05"))
+
+}
+
+func generateOnStop(name string, event Event) string {
+
+	return fmt.Sprintf(`<div style="background-color: #f0f0f0; padding: 20px;">
+		<h1 style="color: #333;">Watcher Stopped</h1>
+		<p style="font-size: 16px;">Hello <strong>%s</strong>, the watcher stopped watching your IP%s address. Here are the details:</p>
+		<ul style="font-size: 16px;">
+			<li><strong>At:</strong> %s</li>
+		</ul>
+	</div>`,
+		name, event.Version, event.Timestamp.Format("2006-01-02 15:04:05"))
+
+}
+
+func generateOnRecover(name string, event Event) string {
+
+	outage := OutageDetails{}
+	if event.Outage != nil {
+		outage = *event.Outage
+	}
+
+	return fmt.Sprintf(`<div style="background-color: #f0f0f0; padding: 20px;">
+		<h1 style="color: #333;">Watcher Recovered</h1>
+		<p style="font-size: 16px;">Hello <strong>%s</strong>, the watcher is able to check your address again. Here are the details:</p>
+		<ul style="font-size: 16px;">
+			<li><strong>Failing Since:</strong> %s</li>
+			<li><strong>Outage Duration:</strong> %s</li>
+			<li><strong>Failed Checks:</strong> %d</li>
+			<li><strong>Recovered at:</strong> %s</li>
+		</ul>
+	</div>`,
+		name, outage.Since.Format("2006-01-02 15:04:05"), outage.Duration.Round(time.Second),
+		outage.Failures, event.Timestamp.Format("2006-01-02 15:04:05"))
+
+}
+
+func generateOnFirstSeen(name string, event Event) string {
+
+	return fmt.Sprintf(`<div style="background-color: #f0f0f0; padding: 20px;">
+		<h1 style="color: #333;">Watcher Update (First Seen)</h1>
+		<p style="font-size: 16px;">Hello <strong>%s</strong>, your public IP address was recorded for the first time. Here are the details:</p>
+		<ul style="font-size: 16px;">
+			<li><strong>Current Address:</strong> %s</li>
+			<li><strong>At:</strong> %s</li>
+			<li><strong>Information Source:</strong> %s</li>
+		</ul>
+	</div>`,
+		name, event.Current, event.Timestamp.Format("2006-01-02 15:04:05"), event.Source)
+
+}
+
+func generateOnDowntime(name string, event Event) string {
+
+	downtime := DowntimeDetails{}
+	if event.Downtime != nil {
+		downtime = *event.Downtime
+	}
+
+	return fmt.Sprintf(`<div style="background-color: #f0f0f0; padding: 20px;">
+		<h1 style="color: #333;">Watcher Update (Downtime)</h1>
+		<p style="font-size: 16px;">Hello <strong>%s</strong>, the watcher started again after being down. Here are the details:</p>
+		<ul style="font-size: 16px;">
+			<li><strong>Latest Check:</strong> %s</li>
+			<li><strong>Downtime:</strong> %s</li>
+			<li><strong>Stopped Gracefully:</strong> %t</li>
+			<li><strong>Started at:</strong> %s</li>
+		</ul>
+	</div>`,
+		name, downtime.Since.Format("2006-01-02 15:04:05"), downtime.Duration.Round(time.Second),
+		downtime.Graceful, event.Timestamp.Format("2006-01-02 15:04:05"))
+
+}
+
+func generateOnPreChange(name string, event Event) string {
+
+	prediction := Prediction{}
+	if event.Prediction != nil {
+		prediction = *event.Prediction
+	}
+
+	return fmt.Sprintf(`<div style="background-color: #f0f0f0; padding: 20px;">
+		<h1 style="color: #333;">Watcher Update (Predicted Change)</h1>
+		<p style="font-size: 16px;">Hello <strong>%s</strong>, your public IP address is expected to change soon. Here are the details:</p>
+		<ul style="font-size: 16px;">
+			<li><strong>Expected at:</strong> %s</li>
+			<li><strong>Window:</strong> %s to %s</li>
+			<li><strong>Period:</strong> %s</li>
+			<li><strong>Latest Change:</strong> %s</li>
+		</ul>
+	</div>`,
+		name, prediction.Expected.Format("2006-01-02 15:04:05"), prediction.Start.Format("2006-01-02 15:04:05"),
+		prediction.End.Format("2006-01-02 15:04:05"), prediction.Period.Round(time.Minute),
+		prediction.LastChange.Format("2006-01-02 15:04:05"))
+
+}
+
+// generateOnEvent is the fallback section for the events without a dedicated generator
+func generateOnEvent(name string, event Event) string {
+
+	return fmt.Sprintf(`<div style="background-color: #f0f0f0; padding: 20px;">
+		<h1 style="color: #333;">Watcher Update (%s)</h1>
+		<p style="font-size: 16px;">Hello <strong>%s</strong>, something happened while watching your address. Here are the details:</p>
+		<ul style="font-size: 16px;">
+			<li><strong>Current Address:</strong> %s</li>
+			<li><strong>At:</strong> %s</li>
+			<li><strong>Information Source:</strong> %s</li>
+		</ul>
+	</div>`,
+		event.Type, name, event.Current, event.Timestamp.Format("2006-01-02 15:04:05"), event.Source)
 
 }
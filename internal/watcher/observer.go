@@ -0,0 +1,60 @@
+package watcher
+
+import (
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/config"
+	"github.com/gweebg/ipwatcher/internal/database"
+)
+
+// Observer records every check as a database.Observation, sampling the successful ones.
+// The expired observations are pruned by the Pruner.
+type Observer struct {
+	settings config.Observations
+	store    database.Store
+
+	// skipped is the number of successful checks not recorded since the latest recorded one
+	skipped int
+}
+
+// NewObserver creates an Observer, recording on store with the settings defined under
+// 'watcher.observations'. Returns nil if the recording of checks is disabled.
+func NewObserver(store database.Store) *Observer {
+
+	c := config.GetConfig()
+
+	settings := c.Get("watcher.observations").(*config.Observations)
+	if !settings.Enabled {
+		return nil
+	}
+
+	return &Observer{
+		settings: *settings,
+		store:    store,
+	}
+}
+
+// Observe records a check of the version, that obtained address from source in latency,
+// or failed with err. Successful checks are recorded one in every 'watcher.observations.sample'.
+func (o *Observer) Observe(version string, address string, source string, err error, latency time.Duration) error {
+
+	observation := database.Observation{
+		Timestamp: time.Now(),
+		Version:   version,
+		Source:    source,
+		Address:   address,
+		Latency:   latency.Milliseconds(),
+	}
+
+	if err != nil {
+		observation.Address = ""
+		observation.Error = err.Error()
+	} else if o.skipped+1 < o.settings.Sample {
+		o.skipped++
+		return nil
+	}
+	o.skipped = 0
+
+	_, err = o.store.CreateObservation(observation)
+	return err
+}
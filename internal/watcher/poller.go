@@ -0,0 +1,120 @@
+package watcher
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/config"
+)
+
+// checkResult is the outcome of a single address check
+type checkResult int
+
+const (
+	// checkFailed indicates that the check could not be completed
+	checkFailed checkResult = iota
+	// checkMatched indicates that the address did not change
+	checkMatched
+	// checkPending indicates that a change was observed but is not yet confirmed
+	checkPending
+	// checkChanged indicates that a change was committed
+	checkChanged
+)
+
+// Poller computes the interval until the next check. The interval backs off
+// exponentially while checks fail, is temporarily shortened after a change or
+// a network signal, and is randomly jittered so watchers don't poll in lockstep.
+type Poller struct {
+	// Interval is the base interval between checks, set by 'watcher.timeout'
+	Interval time.Duration
+
+	settings config.Polling
+
+	// failures is the number of consecutive failed checks
+	failures int
+	// fastUntil is the moment until which the fast interval is used
+	fastUntil time.Time
+
+	// windowFrom and windowUntil bound the predicted change window, during which the
+	// windowInterval is used
+	windowFrom     time.Time
+	windowUntil    time.Time
+	windowInterval time.Duration
+}
+
+// NewPoller creates a Poller with the given base interval and the settings defined
+// under 'watcher.polling'.
+func NewPoller(interval time.Duration) *Poller {
+
+	c := config.GetConfig()
+
+	return &Poller{
+		Interval: interval,
+		settings: *c.Get("watcher.polling").(*config.Polling),
+	}
+}
+
+// Record updates the poller state with the outcome of a check
+func (p *Poller) Record(result checkResult) {
+
+	if result == checkFailed {
+		p.failures++
+		return
+	}
+	p.failures = 0
+
+	if result == checkChanged || result == checkPending {
+		p.Hurry()
+	}
+}
+
+// Hurry switches to the fast interval for 'watcher.polling.fast_duration' seconds
+func (p *Poller) Hurry() {
+	duration := time.Duration(p.settings.FastDuration) * time.Second
+	p.fastUntil = time.Now().Add(duration)
+}
+
+// Window uses interval between from and until, the window in which a change is predicted.
+// The zero times clear the window.
+func (p *Poller) Window(from time.Time, until time.Time, interval time.Duration) {
+	p.windowFrom, p.windowUntil, p.windowInterval = from, until, interval
+}
+
+// Next returns the interval to wait until the next check
+func (p *Poller) Next() time.Duration {
+
+	interval := p.Interval
+
+	fastInterval := time.Duration(p.settings.FastInterval) * time.Second
+	if p.failures > 0 {
+
+		backoff := float64(interval) * math.Pow(p.settings.BackoffFactor, float64(p.failures))
+
+		maxInterval := float64(time.Duration(p.settings.BackoffMax) * time.Second)
+		if backoff > maxInterval {
+			backoff = max(maxInterval, float64(interval))
+		}
+		interval = time.Duration(backoff)
+
+	} else if fastInterval > 0 && fastInterval < interval && time.Now().Before(p.fastUntil) {
+		interval = fastInterval
+	}
+
+	// tighten the interval during the predicted window, waking up as it starts
+	if now := time.Now(); p.failures == 0 && now.Before(p.windowUntil) {
+		if now.Before(p.windowFrom) {
+			interval = min(interval, p.windowFrom.Sub(now))
+		} else {
+			interval = min(interval, p.windowInterval)
+		}
+	}
+
+	// jitter the interval by up to +/- 'watcher.polling.jitter' of its value
+	if p.settings.Jitter > 0 {
+		jitter := (rand.Float64()*2 - 1) * p.settings.Jitter
+		interval += time.Duration(float64(interval) * jitter)
+	}
+
+	return max(interval, time.Second)
+}
@@ -0,0 +1,78 @@
+package watcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/config"
+)
+
+func newTestPoller(settings config.Polling) *Poller {
+	return &Poller{Interval: time.Minute, settings: settings}
+}
+
+func TestPollerBackoff(t *testing.T) {
+
+	p := newTestPoller(config.Polling{BackoffFactor: 2, BackoffMax: 300})
+
+	expected := []time.Duration{2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, interval := range expected {
+		p.Record(checkFailed)
+		if next := p.Next(); next != interval {
+			t.Fatalf("expected %v after %d failures, got %v", interval, i+1, next)
+		}
+	}
+
+	p.Record(checkMatched)
+	if next := p.Next(); next != time.Minute {
+		t.Fatalf("expected the base interval after a successful check, got %v", next)
+	}
+}
+
+func TestPollerFast(t *testing.T) {
+
+	p := newTestPoller(config.Polling{BackoffFactor: 2, BackoffMax: 300, FastInterval: 10, FastDuration: 60})
+
+	p.Record(checkChanged)
+	if next := p.Next(); next != 10*time.Second {
+		t.Fatalf("expected the fast interval after a change, got %v", next)
+	}
+
+	p.fastUntil = time.Now().Add(-time.Second)
+	if next := p.Next(); next != time.Minute {
+		t.Fatalf("expected the base interval once the fast duration is over, got %v", next)
+	}
+}
+
+func TestPollerWindow(t *testing.T) {
+
+	p := newTestPoller(config.Polling{BackoffFactor: 2, BackoffMax: 300})
+	now := time.Now()
+
+	// ahead of the window, wakes up as it starts
+	p.Window(now.Add(30*time.Second), now.Add(time.Hour), 20*time.Second)
+	if next := p.Next(); next > 30*time.Second || next < 29*time.Second {
+		t.Fatalf("expected to wake up as the window starts, got %v", next)
+	}
+
+	p.Window(now.Add(-time.Minute), now.Add(time.Hour), 20*time.Second)
+	if next := p.Next(); next != 20*time.Second {
+		t.Fatalf("expected the window interval within the window, got %v", next)
+	}
+
+	p.Window(time.Time{}, time.Time{}, 0)
+	if next := p.Next(); next != time.Minute {
+		t.Fatalf("expected the base interval once the window is cleared, got %v", next)
+	}
+}
+
+func TestPollerJitter(t *testing.T) {
+
+	p := newTestPoller(config.Polling{BackoffFactor: 2, BackoffMax: 300, Jitter: 0.5})
+
+	for i := 0; i < 100; i++ {
+		if next := p.Next(); next < 30*time.Second || next > 90*time.Second {
+			t.Fatalf("expected the interval to be jittered by up to half of it, got %v", next)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package watcher
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/config"
+	"github.com/gweebg/ipwatcher/internal/database"
+	"github.com/rs/zerolog"
+)
+
+// minPredictionMargin is the minimum margin of the predicted window on each side of the expected change
+const minPredictionMargin = 5 * time.Minute
+
+// Prediction is the window in which the next address change is expected, learnt from
+// the intervals between the latest changes
+type Prediction struct {
+	// Expected is the moment the next change is expected at
+	Expected time.Time `json:"expected"`
+	// Start and End bound the predicted window
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// Period is the median interval between the regular changes
+	Period time.Duration `json:"period"`
+	// Deviation is the standard deviation of the regular intervals from Period
+	Deviation time.Duration `json:"deviation"`
+	// Samples is the number of regular intervals the prediction is based on
+	Samples int `json:"samples"`
+	// LastChange is the moment of the latest change
+	LastChange time.Time `json:"last_change"`
+}
+
+// Predict predicts the next change from the address records, oldest first, as of now.
+// Only the records that are changes are learnt from, not the first runs, seeded baselines
+// nor reset markers. Intervals within half of the median interval from it are regular, the
+// others being ad hoc changes (e.g. a router reboot). Returns nil if the changes are not periodic.
+func Predict(entries []database.AddressEntry, settings config.Prediction, now time.Time) *Prediction {
+
+	var changes []database.AddressEntry
+	for _, entry := range entries {
+		if entry.IsChange() {
+			changes = append(changes, entry)
+		}
+	}
+
+	if len(changes) > settings.History {
+		changes = changes[len(changes)-settings.History:]
+	}
+
+	var intervals []time.Duration
+	for i := 1; i < len(changes); i++ {
+		if changes[i].CreatedAt > changes[i-1].CreatedAt {
+			interval := time.Duration(changes[i].CreatedAt-changes[i-1].CreatedAt) * time.Second
+			intervals = append(intervals, interval)
+		}
+	}
+
+	if len(intervals) < settings.MinChanges {
+		return nil
+	}
+
+	period := median(intervals)
+
+	var regular []time.Duration
+	for _, interval := range intervals {
+		if math.Abs(float64(interval-period)) <= float64(period)/2 {
+			regular = append(regular, interval)
+		}
+	}
+
+	// most of the intervals must be regular, and there must be enough of them
+	if len(regular) < settings.MinChanges || len(regular)*2 < len(intervals) {
+		return nil
+	}
+
+	var variance float64
+	for _, interval := range regular {
+		variance += math.Pow(float64(interval-period), 2)
+	}
+	deviation := time.Duration(math.Sqrt(variance / float64(len(regular))))
+
+	if float64(deviation) > settings.MaxDeviation*float64(period) {
+		return nil
+	}
+
+	margin := max(2*deviation, minPredictionMargin)
+	lastChange := time.Unix(int64(changes[len(changes)-1].CreatedAt), 0)
+
+	// if the expected change did not happen, the next one is expected a period later
+	expected := lastChange.Add(period)
+	for expected.Add(margin).Before(now) {
+		expected = expected.Add(period)
+	}
+
+	return &Prediction{
+		Expected:   expected,
+		Start:      expected.Add(-margin),
+		End:        expected.Add(margin),
+		Period:     period,
+		Deviation:  deviation,
+		Samples:    len(regular),
+		LastChange: lastChange,
+	}
+}
+
+// PredictFrom predicts the next change of the version from the address records of store,
+// with the settings defined under 'watcher.prediction'
+func PredictFrom(store database.Store, version string, now time.Time) (*Prediction, error) {
+
+	c := config.GetConfig()
+	settings := c.Get("watcher.prediction").(*config.Prediction)
+
+	changes, err := store.Changes(version, settings.History)
+	if err != nil {
+		return nil, err
+	}
+
+	return Predict(changes, *settings, now), nil
+}
+
+// Predictor keeps the prediction of the next change up to date, when enabled under
+// 'watcher.prediction', raising on_pre_change 'watcher.prediction.warning' seconds ahead
+// of the predicted window.
+type Predictor struct {
+	settings config.Prediction
+
+	// changes are the latest 'watcher.prediction.history' changes, loaded on the first update
+	// and reloaded after each change
+	changes []database.AddressEntry
+	loaded  bool
+
+	// prediction is the current prediction, nil if the changes are not periodic
+	prediction *Prediction
+	// warning raises on_pre_change for the current prediction
+	warning *time.Timer
+	publish func(Event)
+
+	logger zerolog.Logger
+}
+
+// NewPredictor creates a Predictor publishing on_pre_change with publish, returns nil if
+// the prediction is not enabled.
+func NewPredictor(publish func(Event)) *Predictor {
+
+	c := config.GetConfig()
+
+	settings := c.Get("watcher.prediction").(*config.Prediction)
+	if !settings.Enabled {
+		return nil
+	}
+
+	return &Predictor{
+		settings: *settings,
+		publish:  publish,
+		logger:   GetLogger().With().Str("service", "predictor").Logger(),
+	}
+}
+
+// Update predicts the next change of the version, if there is no prediction yet, its window
+// is over, or the address changed since it was made. The changes are only loaded from store
+// on the first update and after a change.
+func (p *Predictor) Update(store database.Store, version string, changed bool) error {
+
+	if !p.loaded || changed {
+		changes, err := store.Changes(version, p.settings.History)
+		if err != nil {
+			return err
+		}
+		p.changes, p.loaded = changes, true
+	}
+
+	now := time.Now()
+	if p.prediction != nil && !changed && now.Before(p.prediction.End) {
+		return nil
+	}
+
+	previous := p.prediction
+	p.prediction = Predict(p.changes, p.settings, now)
+
+	if p.prediction == nil {
+		if previous != nil {
+			p.logger.Info().Msg("address changes are no longer periodic, dropping the prediction")
+		}
+		p.stopWarning()
+		return nil
+	}
+
+	if previous == nil || !previous.Expected.Equal(p.prediction.Expected) {
+		p.logger.Info().
+			Time("expected", p.prediction.Expected).
+			Dur("period", p.prediction.Period).
+			Int("samples", p.prediction.Samples).
+			Msg("predicted the next address change")
+
+		p.scheduleWarning(now)
+	}
+
+	return nil
+}
+
+// Window returns the moments between which the polling is tightened, from 'watcher.prediction.lead'
+// seconds before the predicted window until its end, and the interval used meanwhile. Returns
+// the zero values if there is no prediction.
+func (p *Predictor) Window() (time.Time, time.Time, time.Duration) {
+
+	if p.prediction == nil {
+		return time.Time{}, time.Time{}, 0
+	}
+
+	lead := time.Duration(p.settings.Lead) * time.Second
+	interval := time.Duration(p.settings.Interval) * time.Second
+
+	return p.prediction.Start.Add(-lead), p.prediction.End, interval
+}
+
+// Stop cancels the pending on_pre_change
+func (p *Predictor) Stop() {
+	p.stopWarning()
+}
+
+// scheduleWarning schedules on_pre_change for the current prediction, if enabled
+func (p *Predictor) scheduleWarning(now time.Time) {
+
+	p.stopWarning()
+
+	if p.settings.Warning == 0 {
+		return
+	}
+
+	// raised right away if already within the warning period, but not once the window started
+	if !now.Before(p.prediction.Start) {
+		return
+	}
+	at := p.prediction.Start.Add(-time.Duration(p.settings.Warning) * time.Second)
+
+	prediction := *p.prediction
+	p.warning = time.AfterFunc(max(at.Sub(now), 0), func() {
+		event := NewEvent(EventPreChange, "")
+		event.Prediction = &prediction
+		p.publish(event) // handle on_pre_change
+	})
+}
+
+func (p *Predictor) stopWarning() {
+	if p.warning != nil {
+		p.warning.Stop()
+		p.warning = nil
+	}
+}
+
+// median returns the median of the durations
+func median(durations []time.Duration) time.Duration {
+
+	sorted := append([]time.Duration{}, durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+
+	return sorted[middle]
+}
@@ -0,0 +1,76 @@
+package watcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/config"
+	"github.com/gweebg/ipwatcher/internal/database"
+)
+
+var predictionSettings = config.Prediction{History: 30, MinChanges: 3, MaxDeviation: 0.1}
+
+// dailyChanges are changes every 24 hours, preceded by a first run and a reset marker
+// that are not learnt from
+var dailyChanges = []database.AddressEntry{
+	recordAt(0, "203.0.113.1", "203.0.113.1"),
+	recordAt(1, "", "203.0.113.1"),
+	recordAt(2, "203.0.113.1", "203.0.113.1"),
+	recordAt(24, "203.0.113.2", "203.0.113.1"),
+	recordAt(48, "203.0.113.3", "203.0.113.2"),
+	recordAt(72, "203.0.113.4", "203.0.113.3"),
+	recordAt(96, "203.0.113.5", "203.0.113.4"),
+}
+
+func TestPredict(t *testing.T) {
+
+	prediction := Predict(dailyChanges, predictionSettings, statsBase.Add(100*time.Hour))
+	if prediction == nil {
+		t.Fatal("expected a prediction for daily changes")
+	}
+
+	expected := statsBase.Add(120 * time.Hour)
+	if !prediction.Expected.Equal(expected) || prediction.Period != 24*time.Hour || prediction.Samples != 3 ||
+		prediction.Deviation != 0 {
+		t.Fatalf("expected the next change at %v, every 24 hours from 3 samples, got %+v", expected, *prediction)
+	}
+
+	if !prediction.Start.Equal(expected.Add(-minPredictionMargin)) || !prediction.End.Equal(expected.Add(minPredictionMargin)) {
+		t.Fatalf("expected the window to have the minimum margin, got %v to %v", prediction.Start, prediction.End)
+	}
+}
+
+func TestPredictOverdue(t *testing.T) {
+
+	// the expected change did not happen, the next one is expected a period later
+	prediction := Predict(dailyChanges, predictionSettings, statsBase.Add(121*time.Hour))
+	if prediction == nil || !prediction.Expected.Equal(statsBase.Add(144*time.Hour)) {
+		t.Fatalf("expected the next change a period after the missed one, got %+v", prediction)
+	}
+}
+
+func TestPredictIrregular(t *testing.T) {
+
+	entries := []database.AddressEntry{
+		recordAt(0, "203.0.113.1", "203.0.113.0"),
+		recordAt(1, "203.0.113.2", "203.0.113.1"),
+		recordAt(11, "203.0.113.3", "203.0.113.2"),
+		recordAt(41, "203.0.113.4", "203.0.113.3"),
+		recordAt(43, "203.0.113.5", "203.0.113.4"),
+	}
+
+	if prediction := Predict(entries, predictionSettings, statsBase.Add(50*time.Hour)); prediction != nil {
+		t.Fatalf("expected no prediction for irregular changes, got %+v", *prediction)
+	}
+}
+
+func TestPredictHistory(t *testing.T) {
+
+	// with a history of 3 changes, only 2 intervals are left, fewer than needed
+	settings := predictionSettings
+	settings.History = 3
+
+	if prediction := Predict(dailyChanges, settings, statsBase.Add(100*time.Hour)); prediction != nil {
+		t.Fatalf("expected no prediction from the latest 3 changes only, got %+v", *prediction)
+	}
+}
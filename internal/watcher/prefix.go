@@ -0,0 +1,58 @@
+package watcher
+
+import (
+	"net/netip"
+
+	"github.com/gweebg/ipwatcher/internal/database"
+)
+
+// addressPrefix returns the network prefix of address, masked to length bits, in CIDR notation
+func addressPrefix(address string, length int) (string, error) {
+
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return "", err
+	}
+
+	prefix, err := addr.Prefix(length)
+	if err != nil {
+		return "", err
+	}
+
+	return prefix.String(), nil
+}
+
+// prefixOf returns the delegated prefix of address when the watcher tracks prefixes,
+// otherwise an empty string is returned.
+func (w *Watcher) prefixOf(address string) (string, error) {
+
+	if w.PrefixLength == 0 {
+		return "", nil
+	}
+
+	return addressPrefix(address, w.PrefixLength)
+}
+
+// changed reports whether the address (with its prefix) differs from the previous record.
+// When tracking prefixes only a different prefix counts as a change, so privacy addresses
+// rotating within the same delegation are ignored.
+func (w *Watcher) changed(address string, prefix string, previous *database.AddressEntry) bool {
+
+	if prefix == "" {
+		return address != previous.Address
+	}
+
+	return prefix != w.recordPrefix(previous)
+}
+
+// recordPrefix returns the prefix stored on entry, computing it from the address for
+// records created before prefix tracking was enabled.
+func (w *Watcher) recordPrefix(entry *database.AddressEntry) string {
+
+	if entry.Prefix != "" {
+		return entry.Prefix
+	}
+
+	prefix, _ := w.prefixOf(entry.Address)
+	return prefix
+}
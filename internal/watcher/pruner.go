@@ -0,0 +1,161 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/config"
+	"github.com/gweebg/ipwatcher/internal/database"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog"
+)
+
+// Pruned holds the number of records removed by a prune
+type Pruned struct {
+	// Entries is the number of deleted address records
+	Entries int64
+	// Observations is the number of deleted observations, downsampled into the daily
+	// summaries if Summarized is set
+	Observations int64
+	Summarized   bool
+	// Outcomes is the number of deleted handled events, along with their outcomes
+	Outcomes int64
+}
+
+// Pruner enforces the retention policies defined under 'database.retention' every
+// 'database.prune_interval' seconds, and vacuums the database at 'database.vacuum'.
+type Pruner struct {
+	settings config.Database
+	store    database.Store
+
+	// vacuum is the schedule of the vacuums, nil if disabled
+	vacuum cron.Schedule
+	// report reports the errors that occur while running
+	report func(error)
+
+	logger zerolog.Logger
+}
+
+// NewPruner creates a Pruner of the records of store, reporting the errors that
+// occur while running to report.
+func NewPruner(store database.Store, report func(error)) *Pruner {
+
+	c := config.GetConfig()
+	settings := c.Get("database").(*config.Database)
+
+	p := &Pruner{
+		settings: *settings,
+		store:    store,
+		report:   report,
+		logger:   GetLogger().With().Str("service", "pruner").Logger(),
+	}
+
+	if settings.Vacuum != "" {
+		p.vacuum, _ = cron.ParseStandard(settings.Vacuum) // validated on config load
+	}
+
+	return p
+}
+
+// Run prunes the records right away and then every 'database.prune_interval' seconds,
+// vacuuming on schedule, until ctx is cancelled.
+func (p *Pruner) Run(ctx context.Context) {
+
+	ticker := time.NewTicker(time.Duration(p.settings.PruneInterval) * time.Second)
+	defer ticker.Stop()
+
+	var vacuumChan <-chan time.Time
+	var vacuumTimer *time.Timer
+	if p.vacuum != nil {
+		vacuumTimer = time.NewTimer(time.Until(p.vacuum.Next(time.Now())))
+		defer vacuumTimer.Stop()
+		vacuumChan = vacuumTimer.C
+	}
+
+	prune := func() {
+		if _, err := p.Prune(); err != nil {
+			p.report(errors.Join(err, ErrorDatabase))
+		}
+	}
+
+	prune()
+	for {
+		select {
+
+		case <-ticker.C:
+			prune()
+
+		case at := <-vacuumChan:
+			if err := p.Vacuum(); err != nil {
+				p.report(errors.Join(err, ErrorDatabase))
+			}
+			vacuumTimer.Reset(time.Until(p.vacuum.Next(at)))
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Prune removes the records that are past their retention. Expired observations are those
+// made before the UTC day 'database.retention.observations' days ago, so that each summarized
+// day is a whole one.
+func (p *Pruner) Prune() (Pruned, error) {
+
+	retention := p.settings.Retention
+	pruned := Pruned{Summarized: retention.Summaries}
+
+	var err error
+	if retention.Changes > 0 {
+		pruned.Entries, err = p.store.PruneEntries(retention.Changes)
+		if err != nil {
+			return pruned, err
+		}
+	}
+
+	if retention.Observations > 0 {
+
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		before := today.AddDate(0, 0, -retention.Observations)
+
+		if retention.Summaries {
+			pruned.Observations, err = p.store.SummarizeObservations(before)
+		} else {
+			pruned.Observations, err = p.store.PruneObservations(before)
+		}
+		if err != nil {
+			return pruned, err
+		}
+	}
+
+	if retention.Outcomes > 0 {
+		pruned.Outcomes, err = p.store.PruneOutcomes(time.Now().AddDate(0, 0, -retention.Outcomes))
+		if err != nil {
+			return pruned, err
+		}
+	}
+
+	if pruned.Entries > 0 || pruned.Observations > 0 || pruned.Outcomes > 0 {
+		p.logger.Info().
+			Int64("entries", pruned.Entries).
+			Int64("observations", pruned.Observations).
+			Bool("summarized", pruned.Summarized).
+			Int64("outcomes", pruned.Outcomes).
+			Msg("pruned expired records")
+	}
+
+	return pruned, nil
+}
+
+// Vacuum reclaims the space left by the pruned records
+func (p *Pruner) Vacuum() error {
+
+	started := time.Now()
+	if err := p.store.Vacuum(); err != nil {
+		return err
+	}
+
+	p.logger.Info().Dur("took", time.Since(started)).Msg("vacuumed the database")
+	return nil
+}
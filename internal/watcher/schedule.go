@@ -0,0 +1,93 @@
+package watcher
+
+import (
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/config"
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule holds the cron expressions, defined under 'watcher.schedule', at which
+// checks are guaranteed to run in addition to the polling interval.
+type Schedule struct {
+	schedules []cron.Schedule
+}
+
+// NewSchedule creates a Schedule from the configuration, returns nil if no
+// cron expressions are defined.
+func NewSchedule() *Schedule {
+
+	c := config.GetConfig()
+
+	expressions := c.Get("watcher.schedule").([]string)
+	if len(expressions) == 0 {
+		return nil
+	}
+
+	schedules := make([]cron.Schedule, 0, len(expressions))
+	for _, expression := range expressions {
+		schedule, _ := cron.ParseStandard(expression) // validated on config load
+		schedules = append(schedules, schedule)
+	}
+
+	return &Schedule{schedules: schedules}
+}
+
+// Next returns the earliest scheduled time after t
+func (s *Schedule) Next(t time.Time) time.Time {
+
+	var next time.Time
+	for _, schedule := range s.schedules {
+		candidate := schedule.Next(t)
+		if next.IsZero() || candidate.Before(next) {
+			next = candidate
+		}
+	}
+
+	return next
+}
+
+// QuietHours holds the daily windows, defined under 'watcher.quiet_hours', during
+// which notifications are held.
+type QuietHours struct {
+	windows []config.QuietHours
+}
+
+// NewQuietHours creates a QuietHours from the configuration, returns nil if no
+// windows are defined.
+func NewQuietHours() *QuietHours {
+
+	c := config.GetConfig()
+
+	windows := c.Get("watcher.quiet_hours").([]config.QuietHours)
+	if len(windows) == 0 {
+		return nil
+	}
+
+	return &QuietHours{windows: windows}
+}
+
+// Active reports whether t falls within a quiet window, alongside the moment that window ends
+func (q *QuietHours) Active(t time.Time) (bool, time.Time) {
+
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+
+	for _, window := range q.windows {
+
+		start, end, _ := window.Times() // validated on config load
+
+		switch {
+		case start <= end && offset >= start && offset < end:
+			return true, midnight.Add(end)
+
+		case start > end && offset >= start: // spans midnight, ends tomorrow
+			return true, midnight.AddDate(0, 0, 1).Add(end)
+
+		case start > end && offset < end: // spans midnight, started yesterday
+			return true, midnight.Add(end)
+		}
+	}
+
+	return false, time.Time{}
+}
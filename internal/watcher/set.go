@@ -0,0 +1,113 @@
+package watcher
+
+import (
+	"errors"
+	"net"
+	"slices"
+
+	"github.com/gweebg/ipwatcher/internal/database"
+)
+
+// RequestAddresses returns the sorted set of current addresses of the given version, made of
+// the source reported address and every public address assigned to the given local interfaces
+// (all interfaces if none is given).
+func (f *Fetcher) RequestAddresses(version string, interfaces []string) ([]string, string, error) {
+
+	addresses, err := localAddresses(interfaces, version)
+	if err != nil {
+		f.logger.Error().Err(err).Msg("failed to list local interface addresses")
+	}
+
+	address, source, sourceErr := f.RequestAddress(version)
+	if sourceErr == nil {
+		addresses = append(addresses, address)
+	}
+
+	if len(addresses) == 0 {
+		return nil, "", errors.Join(sourceErr, err)
+	}
+
+	slices.Sort(addresses)
+	return slices.Compact(addresses), source, nil
+}
+
+// localAddresses lists the public addresses of the given version assigned to the
+// interfaces named by names, or to every interface if names is empty.
+func localAddresses(names []string, version string) ([]string, error) {
+
+	var ifaces []net.Interface
+	if len(names) == 0 {
+
+		all, err := net.Interfaces()
+		if err != nil {
+			return nil, err
+		}
+		ifaces = all
+
+	} else {
+
+		for _, name := range names {
+			iface, err := net.InterfaceByName(name)
+			if err != nil {
+				return nil, err
+			}
+			ifaces = append(ifaces, *iface)
+		}
+	}
+
+	var addresses []string
+	for _, iface := range ifaces {
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, addr := range addrs {
+
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || !ipNet.IP.IsGlobalUnicast() || ipNet.IP.IsPrivate() {
+				continue
+			}
+
+			isV4 := ipNet.IP.To4() != nil
+			if (version == "v4" && isV4) || (version == "v6" && !isV4) {
+				addresses = append(addresses, ipNet.IP.String())
+			}
+		}
+	}
+
+	return addresses, nil
+}
+
+// diffSets returns the addresses present in current but not in previous (added),
+// and the ones present in previous but not in current (removed).
+func diffSets(previous []string, current []string) ([]string, []string) {
+
+	var added, removed []string
+
+	for _, address := range current {
+		if !slices.Contains(previous, address) {
+			added = append(added, address)
+		}
+	}
+
+	for _, address := range previous {
+		if !slices.Contains(current, address) {
+			removed = append(removed, address)
+		}
+	}
+
+	return added, removed
+}
+
+// previousSet returns the address set of the entry, falling back to its single address
+// for records created before set mode was enabled.
+func previousSet(entry *database.AddressEntry) []string {
+
+	if set := entry.AddressSet(); set != nil {
+		return set
+	}
+
+	return []string{entry.Address}
+}
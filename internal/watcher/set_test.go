@@ -0,0 +1,49 @@
+package watcher
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/gweebg/ipwatcher/internal/database"
+)
+
+func TestDiffSets(t *testing.T) {
+
+	cases := []struct {
+		name     string
+		previous []string
+		current  []string
+		added    []string
+		removed  []string
+	}{
+		{"equal", []string{"203.0.113.1", "203.0.113.2"}, []string{"203.0.113.1", "203.0.113.2"}, nil, nil},
+		{"added", []string{"203.0.113.1"}, []string{"203.0.113.1", "203.0.113.2"}, []string{"203.0.113.2"}, nil},
+		{"removed", []string{"203.0.113.1", "203.0.113.2"}, []string{"203.0.113.2"}, nil, []string{"203.0.113.1"}},
+		{"replaced", []string{"203.0.113.1"}, []string{"203.0.113.2"}, []string{"203.0.113.2"}, []string{"203.0.113.1"}},
+		{"from empty", nil, []string{"203.0.113.1"}, []string{"203.0.113.1"}, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+
+			added, removed := diffSets(c.previous, c.current)
+			if !slices.Equal(added, c.added) || !slices.Equal(removed, c.removed) {
+				t.Fatalf("expected %v added and %v removed, got %v and %v", c.added, c.removed, added, removed)
+			}
+		})
+	}
+}
+
+func TestPreviousSet(t *testing.T) {
+
+	snapshot := database.AddressEntry{Address: "203.0.113.1", Addresses: "203.0.113.1,203.0.113.2"}
+	if set := previousSet(&snapshot); !slices.Equal(set, []string{"203.0.113.1", "203.0.113.2"}) {
+		t.Fatalf("expected the stored snapshot, got %v", set)
+	}
+
+	// records created before set mode was enabled only have an address
+	single := database.AddressEntry{Address: "203.0.113.1"}
+	if set := previousSet(&single); !slices.Equal(set, []string{"203.0.113.1"}) {
+		t.Fatalf("expected the single address, got %v", set)
+	}
+}
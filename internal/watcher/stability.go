@@ -0,0 +1,127 @@
+package watcher
+
+import (
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/config"
+)
+
+// Observation is the outcome of observing a value with a Stabilizer
+type Observation struct {
+	// Commit indicates that the changed value is confirmed and can be committed
+	Commit bool
+	// FlapStarted indicates that the value has just started flapping
+	FlapStarted bool
+	// FlapEnded indicates that the value has just stabilized after flapping
+	FlapEnded bool
+	// Changes is the number of changes observed within the flapping window
+	Changes int
+}
+
+// Stabilizer debounces address changes and detects flapping. A changed value is only
+// committed after being observed for a number of consecutive checks or for a period of
+// time, and while flapping no change is committed at all.
+type Stabilizer struct {
+	confirm  config.Confirm
+	flapping config.Flapping
+
+	// candidate is the changed value waiting for confirmation
+	candidate      string
+	candidateSince time.Time
+	candidateCount int
+
+	// last is the last observed value, used to count changes
+	last    string
+	changes []time.Time
+
+	isFlapping bool
+}
+
+// NewStabilizer creates a Stabilizer from the 'watcher.confirm' and 'watcher.flapping'
+// configuration, by default changes are committed immediately and flapping is not detected.
+func NewStabilizer() *Stabilizer {
+
+	c := config.GetConfig()
+
+	return &Stabilizer{
+		confirm:  *c.Get("watcher.confirm").(*config.Confirm),
+		flapping: *c.Get("watcher.flapping").(*config.Flapping),
+	}
+}
+
+// Observe records value as observed at the given time, changed indicates whether
+// it differs from the committed value.
+func (s *Stabilizer) Observe(value string, changed bool, at time.Time) Observation {
+
+	var observation Observation
+
+	if s.last != "" && value != s.last {
+		s.changes = append(s.changes, at)
+	}
+	s.last = value
+
+	observation.Changes = s.countChanges(at)
+	if s.flapping.Changes > 0 {
+
+		flapping := observation.Changes >= s.flapping.Changes
+
+		observation.FlapStarted = flapping && !s.isFlapping
+		observation.FlapEnded = !flapping && s.isFlapping
+		s.isFlapping = flapping
+	}
+
+	if !changed {
+		s.candidate = ""
+		return observation
+	}
+
+	if value != s.candidate {
+		s.candidate = value
+		s.candidateSince = at
+		s.candidateCount = 0
+	}
+	s.candidateCount++
+
+	observation.Commit = !s.isFlapping && s.confirmed(at)
+	if observation.Commit {
+		s.candidate = ""
+	}
+
+	return observation
+}
+
+// Flapping reports whether the value is currently flapping
+func (s *Stabilizer) Flapping() bool {
+	return s.isFlapping
+}
+
+// confirmed reports whether the candidate satisfies either of the confirmation rules
+func (s *Stabilizer) confirmed(at time.Time) bool {
+
+	if s.confirm.Checks <= 1 && s.confirm.Seconds == 0 {
+		return true // no confirmation rule configured
+	}
+
+	if s.confirm.Checks > 1 && s.candidateCount >= s.confirm.Checks {
+		return true
+	}
+
+	seconds := time.Duration(s.confirm.Seconds) * time.Second
+	return s.confirm.Seconds > 0 && at.Sub(s.candidateSince) >= seconds
+}
+
+// countChanges drops the changes outside the flapping window and returns how many remain
+func (s *Stabilizer) countChanges(at time.Time) int {
+
+	window := time.Duration(s.flapping.Window) * time.Second
+
+	kept := s.changes[:0]
+	for _, change := range s.changes {
+		if at.Sub(change) < window {
+			kept = append(kept, change)
+		}
+	}
+	s.changes = kept
+
+	return len(s.changes)
+}
@@ -0,0 +1,79 @@
+package watcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/config"
+)
+
+func TestStabilizerImmediate(t *testing.T) {
+
+	s := &Stabilizer{}
+	now := time.Now()
+
+	s.Observe("203.0.113.1", false, now)
+	if observation := s.Observe("203.0.113.2", true, now); !observation.Commit {
+		t.Fatal("expected the change to be committed right away without confirmation rules")
+	}
+}
+
+func TestStabilizerConfirmChecks(t *testing.T) {
+
+	s := &Stabilizer{confirm: config.Confirm{Checks: 3}}
+	now := time.Now()
+
+	s.Observe("203.0.113.1", false, now)
+	for i := 1; i < 3; i++ {
+		if s.Observe("203.0.113.2", true, now).Commit {
+			t.Fatalf("expected the change not to be committed after %d checks", i)
+		}
+	}
+
+	// going back to the committed address restarts the confirmation
+	s.Observe("203.0.113.1", false, now)
+	if s.Observe("203.0.113.2", true, now).Commit {
+		t.Fatal("expected the confirmation to restart")
+	}
+	s.Observe("203.0.113.2", true, now)
+
+	if !s.Observe("203.0.113.2", true, now).Commit {
+		t.Fatal("expected the change to be committed after 3 consecutive checks")
+	}
+}
+
+func TestStabilizerConfirmSeconds(t *testing.T) {
+
+	s := &Stabilizer{confirm: config.Confirm{Seconds: 60}}
+	start := time.Now()
+
+	if s.Observe("203.0.113.2", true, start).Commit {
+		t.Fatal("expected the change not to be committed when first observed")
+	}
+	if s.Observe("203.0.113.2", true, start.Add(30*time.Second)).Commit {
+		t.Fatal("expected the change not to be committed before 60 seconds")
+	}
+	if !s.Observe("203.0.113.2", true, start.Add(60*time.Second)).Commit {
+		t.Fatal("expected the change to be committed after 60 seconds")
+	}
+}
+
+func TestStabilizerFlapping(t *testing.T) {
+
+	s := &Stabilizer{flapping: config.Flapping{Changes: 3, Window: 60}}
+	start := time.Now()
+
+	s.Observe("203.0.113.1", false, start)
+	s.Observe("203.0.113.2", true, start.Add(10*time.Second))
+	s.Observe("203.0.113.1", false, start.Add(20*time.Second))
+
+	observation := s.Observe("203.0.113.2", true, start.Add(30*time.Second))
+	if !observation.FlapStarted || observation.Changes != 3 || observation.Commit || !s.Flapping() {
+		t.Fatalf("expected the third change within the window to start flapping, got %+v", observation)
+	}
+
+	observation = s.Observe("203.0.113.2", true, start.Add(100*time.Second))
+	if !observation.FlapEnded || !observation.Commit || s.Flapping() {
+		t.Fatalf("expected the flapping to end once the changes leave the window, got %+v", observation)
+	}
+}
@@ -0,0 +1,105 @@
+package watcher
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/database"
+)
+
+// restoreState restores the runtime state persisted by the previous run, if any, and
+// raises on_downtime with the gap between the previous run and the current one.
+func (w *Watcher) restoreState() {
+
+	state, err := w.store.LoadState(w.Version)
+	if err != nil {
+		w.fail(errors.Join(err, ErrorDatabase))
+		return
+	}
+
+	if state == nil {
+		return
+	}
+
+	w.failures = state.ConsecutiveErrors
+	w.failingSince = state.FailingSince
+	w.lastCheck = state.LastCheck
+	w.lastSuccess = state.LastSuccess
+	w.natStatus = state.NatStatus
+
+	// the checks keep backing off if the previous run was failing
+	w.poller.failures = state.ConsecutiveErrors
+
+	if err = w.fetcher.health.restore(state.SourceHealth); err != nil {
+		w.logger.Warn().Err(err).Msg("could not restore the health of the sources")
+	}
+
+	if state.LastCheck.IsZero() {
+		return
+	}
+
+	event := NewEvent(EventDowntime, w.Version)
+	event.Downtime = &DowntimeDetails{
+		Since:    state.LastCheck,
+		Duration: event.Timestamp.Sub(state.LastCheck),
+		Graceful: !state.StoppedAt.Before(state.LastCheck),
+	}
+
+	// an outage ongoing when the previous run stopped carries on, without the downtime
+	if w.failures > 0 {
+		w.outageDowntime = event.Downtime.Duration
+	}
+
+	w.logger.Info().
+		Time("last_check", state.LastCheck).
+		Dur("downtime", event.Downtime.Duration).
+		Int("failures", w.failures).
+		Msg("restored the state of the previous run")
+
+	w.afterDowntime = true
+	w.publish(event) // handle on_downtime
+}
+
+// saveState records the outcome of a check on the runtime state and persists it
+func (w *Watcher) saveState(result checkResult) {
+
+	w.lastCheck = time.Now()
+	if result != checkFailed {
+		w.lastSuccess = w.lastCheck
+	}
+
+	// a conclusive check already compared the address against the one recorded before the downtime
+	if result == checkMatched || result == checkChanged {
+		w.afterDowntime = false
+	}
+
+	w.persistState(time.Time{})
+}
+
+// persistState saves the runtime state on the database, stoppedAt being set when
+// the watcher is stopping gracefully.
+func (w *Watcher) persistState(stoppedAt time.Time) {
+
+	state := database.RuntimeState{
+		Version:           w.Version,
+		ConsecutiveErrors: w.failures,
+		LastCheck:         w.lastCheck,
+		LastSuccess:       w.lastSuccess,
+		StoppedAt:         stoppedAt,
+		NatStatus:         w.natStatus,
+	}
+
+	if w.failures > 0 {
+		state.FailingSince = w.failingSince
+	}
+
+	health, err := w.fetcher.health.encode()
+	if err != nil {
+		w.logger.Warn().Err(err).Msg("could not encode the health of the sources")
+	}
+	state.SourceHealth = health
+
+	if err = w.store.SaveState(state); err != nil {
+		w.fail(errors.Join(err, ErrorDatabase))
+	}
+}
@@ -0,0 +1,201 @@
+package watcher
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/database"
+)
+
+// Stats holds the statistics computed from the address records of a version. Only the records
+// whose address differs from the previous one are changes, the first runs, seeded baselines
+// and reset markers are not.
+type Stats struct {
+	// Version of the address the statistics refer to
+	Version string `json:"version"`
+
+	// Records is the number of address records, reset markers aside, Changes the number of
+	// those that are changes
+	Records int `json:"records"`
+	Changes int `json:"changes"`
+	// From is the moment of the first record, LastChange the moment of the latest change
+	From       time.Time `json:"from"`
+	LastChange time.Time `json:"last_change,omitempty"`
+
+	// ChangesPerDay is the number of changes by UTC day ('YYYY-MM-DD')
+	ChangesPerDay map[string]int `json:"changes_per_day"`
+	// ChangesPerWeek is the number of changes by ISO week ('YYYY-Www')
+	ChangesPerWeek map[string]int `json:"changes_per_week"`
+	// MeanChangesPerDay is the number of changes over the days covered by the records
+	MeanChangesPerDay float64 `json:"mean_changes_per_day"`
+
+	// Leases are the lease statistics of each address, by order of first appearance
+	Leases []LeaseStats `json:"leases"`
+	// MeanLease and MaxLease are the mean and maximum duration of the ended leases
+	MeanLease time.Duration `json:"mean_lease"`
+	MaxLease  time.Duration `json:"max_lease"`
+
+	// LongestStable is the longest period without changes, the current one included
+	LongestStable *StablePeriod `json:"longest_stable,omitempty"`
+
+	// HourOfDay is the number of changes by hour of the day, in local time
+	HourOfDay [24]int `json:"hour_of_day"`
+
+	// Current is the current address and CurrentAge the time since it was recorded
+	Current    string        `json:"current"`
+	CurrentAge time.Duration `json:"current_age"`
+}
+
+// LeaseStats holds the durations an address was held for. A lease is the time between the
+// change to the address and the next change, so the current lease is not accounted for until
+// it ends, nor the ones interrupted by a reset of the baseline.
+type LeaseStats struct {
+	Address string `json:"address"`
+	// Leases is the number of ended leases of the address
+	Leases int `json:"leases"`
+	// Mean and Max are the mean and maximum duration of the ended leases
+	Mean time.Duration `json:"mean"`
+	Max  time.Duration `json:"max"`
+}
+
+// StablePeriod is a period during which the address did not change
+type StablePeriod struct {
+	Address  string        `json:"address"`
+	From     time.Time     `json:"from"`
+	To       time.Time     `json:"to"`
+	Duration time.Duration `json:"duration"`
+	// Current is set if the period is still ongoing
+	Current bool `json:"current"`
+}
+
+// ComputeStats computes the Stats of the address records of version, as of now
+func ComputeStats(version string, entries []database.AddressEntry, now time.Time) Stats {
+
+	stats := Stats{
+		Version:        version,
+		ChangesPerDay:  map[string]int{},
+		ChangesPerWeek: map[string]int{},
+		Leases:         []LeaseStats{},
+	}
+
+	sorted := make([]database.AddressEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsReset() {
+			stats.Records++
+		}
+		sorted = append(sorted, entry)
+	}
+
+	if stats.Records == 0 {
+		return stats
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt < sorted[j].CreatedAt
+	})
+
+	at := func(entry database.AddressEntry) time.Time {
+		return time.Unix(int64(entry.CreatedAt), 0)
+	}
+
+	leases := map[string]*LeaseStats{}
+	var total time.Duration
+	var ended int
+
+	// period is the current stable period, lease set if it started with a change and was
+	// not interrupted by a reset, so that its duration is a whole lease
+	var period *StablePeriod
+	var lease bool
+
+	closePeriod := func(to time.Time, current bool) {
+
+		period.To, period.Duration, period.Current = to, to.Sub(period.From), current
+		if stats.LongestStable == nil || period.Duration > stats.LongestStable.Duration {
+			stats.LongestStable = period
+		}
+
+		if !lease || current {
+			return
+		}
+
+		held := leases[period.Address]
+		held.Mean = (held.Mean*time.Duration(held.Leases) + period.Duration) / time.Duration(held.Leases+1)
+		held.Leases++
+		held.Max = max(held.Max, period.Duration)
+
+		total += period.Duration
+		ended++
+		stats.MaxLease = max(stats.MaxLease, period.Duration)
+	}
+
+	openPeriod := func(entry database.AddressEntry, changed bool) {
+
+		period, lease = &StablePeriod{Address: entry.Address, From: at(entry)}, changed
+		if _, ok := leases[entry.Address]; !ok {
+			leases[entry.Address] = &LeaseStats{Address: entry.Address}
+			stats.Leases = append(stats.Leases, LeaseStats{Address: entry.Address})
+		}
+	}
+
+	for _, entry := range sorted {
+
+		switch {
+
+		case entry.IsReset():
+			lease = false
+
+		case entry.IsChange():
+			changedAt := at(entry)
+
+			stats.Changes++
+			stats.LastChange = changedAt
+			stats.ChangesPerDay[changedAt.UTC().Format("2006-01-02")]++
+
+			year, week := changedAt.UTC().ISOWeek()
+			stats.ChangesPerWeek[isoWeek(year, week)]++
+
+			stats.HourOfDay[changedAt.Local().Hour()]++
+
+			if period != nil {
+				closePeriod(changedAt, false)
+			}
+			openPeriod(entry, true)
+
+		case period == nil:
+			openPeriod(entry, false)
+
+		case entry.Address != period.Address:
+			// a first run after a reset, or a seeded baseline, with another address
+			closePeriod(at(entry), false)
+			openPeriod(entry, false)
+		}
+
+		if stats.From.IsZero() && period != nil {
+			stats.From = period.From // the first record, reset markers aside
+		}
+	}
+
+	stats.Current = period.Address
+	stats.CurrentAge = now.Sub(period.From)
+	closePeriod(now, true)
+
+	if days := now.Sub(stats.From).Hours() / 24; days > 0 {
+		stats.MeanChangesPerDay = float64(stats.Changes) / days
+	}
+
+	for i := range stats.Leases {
+		stats.Leases[i] = *leases[stats.Leases[i].Address]
+	}
+
+	if ended > 0 {
+		stats.MeanLease = total / time.Duration(ended)
+	}
+
+	return stats
+}
+
+// isoWeek formats an ISO year and week as 'YYYY-Www'
+func isoWeek(year int, week int) string {
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
@@ -0,0 +1,84 @@
+package watcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/database"
+)
+
+// statsBase is the moment the records of the statistics and prediction tests start at
+var statsBase = time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+// recordAt returns the record of address, previously previous, created hours after statsBase
+func recordAt(hours int, address string, previous string) database.AddressEntry {
+	return database.AddressEntry{
+		Address:         address,
+		PreviousAddress: previous,
+		Version:         "v4",
+		CreatedAt:       uint64(statsBase.Add(time.Duration(hours) * time.Hour).Unix()),
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+
+	entries := []database.AddressEntry{
+		recordAt(0, "203.0.113.1", "203.0.113.1"),  // first run
+		recordAt(10, "203.0.113.2", "203.0.113.1"), // change
+		recordAt(30, "203.0.113.3", "203.0.113.2"), // change, ending a 20 hours lease
+		recordAt(40, "", "203.0.113.3"),            // reset marker, interrupting the lease
+		recordAt(45, "203.0.113.4", "203.0.113.4"), // first run after the reset
+		recordAt(50, "203.0.113.5", "203.0.113.4"), // change
+	}
+	now := statsBase.Add(60 * time.Hour)
+
+	stats := ComputeStats("v4", entries, now)
+
+	if stats.Records != 5 || stats.Changes != 3 {
+		t.Fatalf("expected 5 records and 3 changes, got %d and %d", stats.Records, stats.Changes)
+	}
+
+	if !stats.From.Equal(statsBase) || !stats.LastChange.Equal(statsBase.Add(50*time.Hour)) {
+		t.Fatalf("expected the records from %v with the last change at 50 hours, got %v and %v", statsBase, stats.From, stats.LastChange)
+	}
+
+	days := map[string]int{"2024-03-10": 1, "2024-03-11": 1, "2024-03-12": 1}
+	for day, changes := range days {
+		if stats.ChangesPerDay[day] != changes {
+			t.Fatalf("expected %d changes on %v, got %v", changes, day, stats.ChangesPerDay)
+		}
+	}
+
+	if stats.MeanLease != 20*time.Hour || stats.MaxLease != 20*time.Hour {
+		t.Fatalf("expected a single ended lease of 20 hours, got a mean of %v and a max of %v", stats.MeanLease, stats.MaxLease)
+	}
+
+	if len(stats.Leases) != 5 {
+		t.Fatalf("expected the leases of 5 addresses, got %+v", stats.Leases)
+	}
+	for _, lease := range stats.Leases {
+		ended := 0
+		if lease.Address == "203.0.113.2" {
+			ended = 1
+		}
+		if lease.Leases != ended {
+			t.Fatalf("expected %d ended leases of %v, got %+v", ended, lease.Address, lease)
+		}
+	}
+
+	if longest := stats.LongestStable; longest == nil || longest.Address != "203.0.113.2" || longest.Duration != 20*time.Hour || longest.Current {
+		t.Fatalf("expected the longest stable period to be the lease of 203.0.113.2, got %+v", longest)
+	}
+
+	if stats.Current != "203.0.113.5" || stats.CurrentAge != 10*time.Hour {
+		t.Fatalf("expected 203.0.113.5 to be current for 10 hours, got %v for %v", stats.Current, stats.CurrentAge)
+	}
+}
+
+func TestComputeStatsEmpty(t *testing.T) {
+
+	stats := ComputeStats("v4", []database.AddressEntry{recordAt(0, "", "203.0.113.1")}, statsBase)
+	if stats.Records != 0 || stats.Current != "" || stats.LongestStable != nil {
+		t.Fatalf("expected no statistics without records, got %+v", stats)
+	}
+}
@@ -2,16 +2,19 @@ package watcher
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"github.com/rs/zerolog"
-	"os"
-	"os/signal"
 	"time"
 
 	"github.com/gweebg/ipwatcher/internal/config"
 	"github.com/gweebg/ipwatcher/internal/database"
 )
 
+// networkSettleTime is the time to wait after a network change notification before
+// checking, as a single reconnect produces a burst of link, address and route changes
+const networkSettleTime = 2 * time.Second
+
 var (
 	// ErrorDatabase represents an error specific to database operations
 	ErrorDatabase = errors.New("database error")
@@ -21,6 +24,8 @@ var (
 	ErrorExecutor = errors.New("executor error")
 	// ErrorFetch represents an error specific to address fetching operations
 	ErrorFetch = errors.New("fetch error")
+	// ErrorNat represents an error specific to NAT detection
+	ErrorNat = errors.New("nat detection error")
 )
 
 // Watcher is the main part of the IP watcher service. According to a defined
@@ -31,6 +36,14 @@ type Watcher struct {
 	Version string
 	// Timeout represents the duration between each address query
 	Timeout time.Duration
+	// PrefixLength is the length of the tracked IPv6 prefix, 0 when tracking full addresses
+	PrefixLength int
+	// Hosts are the LAN hosts whose addresses are derived from the prefix on change
+	Hosts []config.Host
+	// Set holds the set mode settings, nil when tracking a single address
+	Set *config.AddressSet
+	// FirstRunAsChange also raises on_change when the first address is recorded
+	FirstRunAsChange bool
 
 	allowApi  bool
 	allowExec bool
@@ -38,16 +51,60 @@ type Watcher struct {
 	notifier *Notifier
 	fetcher  *Fetcher
 	executor *Executor
-
-	ticker         *time.Ticker
-	tickerQuitChan chan struct{}
-	errorChan      chan error
-	logger         zerolog.Logger
+	nat      *NatDetector
+
+	// stabilizer debounces changes and detects flapping
+	stabilizer *Stabilizer
+	// observer records every check, nil if disabled
+	observer *Observer
+	// store persists the address records and the runtime state
+	store database.Store
+	// pruner enforces the retention policies of the records
+	pruner *Pruner
+
+	// predictor predicts the next change, tightening the polling around it
+	predictor *Predictor
+
+	// natStatus is the last detected NAT status, used to only raise on_nat on transitions
+	natStatus string
+
+	// failures is the number of consecutive failed checks, since failingSince
+	failures     int
+	failingSince time.Time
+	// outageDowntime is the time the watcher was down during the ongoing outage, excluded
+	// from its duration as on_downtime already reports it
+	outageDowntime time.Duration
+
+	// lastCheck and lastSuccess are the moments of the latest check and successful check,
+	// persisted alongside the failures as the runtime state
+	lastCheck   time.Time
+	lastSuccess time.Time
+	// afterDowntime is set until the first conclusive check after a restart, which compares
+	// the address against the one recorded before the watcher went down
+	afterDowntime bool
+
+	// poller schedules the checks, backing off on errors and speeding up on changes
+	poller *Poller
+	// schedule holds the cron expressions at which checks are guaranteed to run
+	schedule *Schedule
+	// networkEvents enables checking on network change notifications (linux only)
+	networkEvents bool
+
+	// ShutdownGrace is the time running actions are given to finish when stopping
+	ShutdownGrace time.Duration
+
+	// bus delivers the events to the notifier, executor, api, metrics and logger
+	bus     *Bus
+	metrics *Metrics
+	api     *Api
+
+	triggerChan chan struct{}
+	logger      zerolog.Logger
 }
 
-// NewWatcher creates a new watcher. Its parameters are set according
-// to the values set on the YAML configuration file.
-func NewWatcher() *Watcher {
+// NewWatcher creates a new watcher, persisting its records on store. Its parameters
+// are set according to the values set on the YAML configuration file.
+func NewWatcher(store database.Store) *Watcher {
 
 	c := config.GetConfig()
 
@@ -57,176 +114,748 @@ func NewWatcher() *Watcher {
 	// only set the notifier and executor if the flags for it are set to true
 	var notifier *Notifier = nil
 	if c.GetBool("flags.notify") {
-		notifier = NewNotifier()
+		notifier = NewNotifier(store)
 	}
 
-	errorChan := make(chan error)
-	var executor *Executor = nil
-	if c.GetBool("flags.exec") {
-		executor = NewExecutor(errorChan)
+	shutdownGrace := 10 * time.Second
+	if c.IsSet("watcher.shutdown_grace") {
+		shutdownGrace = time.Duration(c.GetInt("watcher.shutdown_grace")) * time.Second
 	}
 
-	return &Watcher{
-		Version:   c.GetString("flags.version"),
+	fetcher := NewFetcher()
+
+	// prefix tracking only applies to v6 watchers
+	version := c.GetString("flags.version")
+	prefixLength := 0
+	var hosts []config.Host
+	if version == "v6" {
+		v6 := c.Get("watcher.v6").(*config.V6)
+		prefixLength, hosts = v6.PrefixLength, v6.Hosts
+	}
+
+	w := &Watcher{
+		Version:   version,
 		allowApi:  c.GetBool("flags.api"),
 		allowExec: c.GetBool("flags.exec"),
 
 		notifier: notifier,
-		fetcher:  NewFetcher(),
-		executor: executor,
+		fetcher:  fetcher,
+		nat:      NewNatDetector(fetcher),
+
+		stabilizer: NewStabilizer(),
+		observer:   NewObserver(store),
+		store:      store,
 
-		Timeout: timeout,
-		ticker:  time.NewTicker(timeout),
+		Timeout:      timeout,
+		PrefixLength: prefixLength,
+		Hosts:        hosts,
+		Set:          c.Get("watcher.set").(*config.AddressSet),
 
-		tickerQuitChan: make(chan struct{}),
-		errorChan:      errorChan,
-		logger:         GetLogger().With().Str("service", "watcher").Logger(),
+		FirstRunAsChange: c.GetBool("watcher.first_run_as_change"),
+
+		poller:   NewPoller(timeout),
+		schedule: NewSchedule(),
+
+		networkEvents: !c.IsSet("watcher.network_events") || c.GetBool("watcher.network_events"),
+
+		ShutdownGrace: shutdownGrace,
+
+		bus:     NewBus(),
+		metrics: NewMetrics(),
+
+		triggerChan: make(chan struct{}, 1),
+		logger:      GetLogger().With().Str("service", "watcher").Logger(),
 	}
+
+	// the executor reports its errors through the watcher
+	if c.GetBool("flags.exec") {
+		w.executor = NewExecutor(w.fail, store)
+	}
+
+	w.pruner = NewPruner(store, w.fail)
+	w.predictor = NewPredictor(w.publish)
+
+	if w.allowApi {
+		w.api = NewApi(w.metrics, store)
+	}
+
+	return w
 }
 
-func (w *Watcher) Watch() {
+// Watch runs the watcher until ctx is cancelled (e.g. on SIGINT or SIGTERM), then
+// stops it gracefully, draining the in-flight work before returning.
+func (w *Watcher) Watch(ctx context.Context) {
 
 	w.logger.Info().Msg("watcher service is now running")
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	w.subscribe()
+	if w.api != nil {
+		go w.api.Serve()
+	}
 
-	go w.check()
+	w.publish(NewEvent(EventStart, w.Version)) // handle on_start
+	w.restoreState()
 
-	for sig := range c {
-		w.logger.Warn().Msgf("received %v signal, stopping watcher...", sig.String())
-		w.Stop()
-		return
+	checking := make(chan struct{})
+	go func() {
+		w.check(ctx)
+		close(checking)
+	}()
+
+	pruning := make(chan struct{})
+	go func() {
+		w.pruner.Run(ctx)
+		close(pruning)
+	}()
+
+	if w.networkEvents {
+		go w.networkChanges(ctx)
+	}
+
+	<-ctx.Done()
+	w.logger.Warn().Msg("stopping watcher, waiting for in-flight work to finish...")
+
+	<-checking // let the current check and prune finish
+	<-pruning
+	if w.predictor != nil {
+		w.predictor.Stop()
 	}
+	w.persistState(time.Now())
+
+	w.publish(NewEvent(EventStop, w.Version)) // handle on_stop
+	w.Stop()
+
+	w.logger.Info().Msg("watcher service stopped")
 }
 
+// subscribe registers the event consumers on the bus, each one consuming the
+// published events independently from the others. The notifier and the executor
+// subscribe reliably, as a dropped event there is a missed notification or action.
+func (w *Watcher) subscribe() {
+
+	w.bus.Subscribe("logger", w.logEvent)
+	w.bus.Subscribe("metrics", w.metrics.Record)
+
+	if w.notifier != nil {
+		w.bus.SubscribeReliable("notifier", w.notify)
+	}
+
+	if w.executor != nil {
+		w.bus.SubscribeReliable("executor", w.execute)
+	}
+
+	if w.api != nil {
+		w.bus.Subscribe("api", w.api.Record)
+	}
+}
+
+// Stop drains the in-flight work, waiting for the published events to be handled, giving
+// the running actions 'watcher.shutdown_grace' seconds before killing them, and dropping
+// the notifications held by the quiet hours.
 func (w *Watcher) Stop() {
-	close(w.tickerQuitChan)
-	close(w.errorChan)
+
+	w.bus.Close()
+
+	if w.executor != nil {
+		w.executor.Shutdown(w.ShutdownGrace)
+	}
+
+	if w.notifier != nil {
+		w.notifier.Discard()
+	}
+
+	if w.api != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), w.ShutdownGrace)
+		defer cancel()
+
+		if err := w.api.Shutdown(ctx); err != nil {
+			w.logger.Error().Err(err).Msg("could not stop the api gracefully")
+		}
+	}
 }
 
-func (w *Watcher) HandleEvent(eventType string, ctx context.Context) {
+// publish publishes event on the bus, filling the version it refers to, and records
+// it beforehand if it is handled
+func (w *Watcher) publish(event Event) {
+	event.Version = w.Version
+	w.recordEvent(&event)
+	w.bus.Publish(event)
+}
 
-	c := config.GetConfig()
-	ctx = context.WithValue(ctx, "event", eventType)
+// fail publishes err as an on_error event
+func (w *Watcher) fail(err error) {
+	event := NewEvent(EventError, w.Version)
+	event.Err = err
+	w.publish(event)
+}
+
+// recordEvent records event if it is handled, by notifying or running actions, setting
+// its ID so that the outcomes of its handling refer to it. Failing to record it is only
+// logged, as reporting it would publish yet another event.
+func (w *Watcher) recordEvent(event *Event) {
+
+	handler := w.handlerFor(event.Type)
+	if handler == nil {
+		return
+	}
 
-	var handler *config.EventHandler
+	notified := handler.Notify && w.notifier != nil
+	executed := len(handler.Actions) > 0 && w.executor != nil
+	if !notified && !executed {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		w.logger.Error().Err(err).Str("event", string(event.Type)).Msg("cannot encode event")
+		return
+	}
+
+	record, err := w.store.CreateEventRecord(database.EventRecord{
+		Timestamp: event.Timestamp,
+		Version:   event.Version,
+		Type:      string(event.Type),
+		Current:   event.Current,
+		Previous:  event.Previous,
+		Source:    event.Source,
+		Error:     event.ErrorMessage(),
+		Payload:   string(payload),
+	})
+	if err != nil {
+		w.logger.Error().Err(errors.Join(err, ErrorDatabase)).Str("event", string(event.Type)).Msg("cannot record event")
+		return
+	}
+
+	event.ID = record.ID
+}
+
+// handlerFor returns the configured handler for the event type, nil if not configured
+func (w *Watcher) handlerFor(eventType EventType) *config.EventHandler {
+
+	c := config.GetConfig()
 	events := c.Get("watcher.events").(*config.Events)
 
 	switch eventType {
 
-	case "on_change":
-		handler = events.OnChange
-	case "on_match":
-		handler = events.OnMatch
-	case "on_error":
-		handler = events.OnError
+	case EventChange:
+		return events.OnChange
+	case EventMatch:
+		return events.OnMatch
+	case EventError:
+		return events.OnError
+	case EventNat:
+		return events.OnNat
+	case EventFlapping:
+		return events.OnFlapping
+	case EventStart:
+		return events.OnStart
+	case EventStop:
+		return events.OnStop
+	case EventRecover:
+		return events.OnRecover
+	case EventFirstSeen:
+		return events.OnFirstSeen
+	case EventDowntime:
+		return events.OnDowntime
+	case EventPreChange:
+		return events.OnPreChange
+	}
 
-	default:
-		w.logger.Fatal().Msgf("unknown event type '%v', skipping", eventType)
+	w.logger.Error().Msgf("unknown event type '%v', skipping", eventType)
+	return nil
+}
+
+// notify emails the recipients about the event if its handler enables notifications.
+// Notifier errors are only logged, as notifying about them could fail all the same.
+func (w *Watcher) notify(event Event) {
+
+	handler := w.handlerFor(event.Type)
+	if handler == nil || !handler.Notify {
+		return
 	}
 
-	if handler != nil {
+	err := w.notifier.NotifyMail(event)
+	if err != nil {
+		w.logger.Error().Err(errors.Join(err, ErrorNotifier)).Str("event", string(event.Type)).Msg("unexpected error")
+		return
+	}
 
-		if handler.Notify && w.notifier != nil {
-			err := w.notifier.NotifyMail(ctx)
-			if err != nil {
-				w.errorChan <- errors.Join(err, ErrorNotifier)
-			}
-			w.logger.Info().
-				Str("event", eventType).
-				Msgf("notified %d recipients", len(w.notifier.Recipients))
+	w.logger.Info().
+		Str("event", string(event.Type)).
+		Msgf("notified %d recipients", len(w.notifier.Recipients))
+}
+
+// execute runs the actions defined by the handler of the event
+func (w *Watcher) execute(event Event) {
+
+	handler := w.handlerFor(event.Type)
+	if handler == nil {
+		return
+	}
+
+	w.executor.ExecuteSlice(handler.Actions, event)
+}
+
+// logEvent logs every published event, errors included
+func (w *Watcher) logEvent(event Event) {
+
+	if event.Type == EventError {
+		w.logger.Error().Err(event.Err).Msg("unexpected error")
+		return
+	}
+
+	w.logger.Debug().Str("event", string(event.Type)).Msg("event published")
+}
+
+// check runs the address checks, each scheduled by the poller according to the
+// outcome of the previous check, or by the cron schedule, until the watcher is stopped.
+func (w *Watcher) check(ctx context.Context) {
+
+	// polling is disabled when 'watcher.timeout' is 0, only running scheduled checks
+	timer := time.NewTimer(w.poller.Next())
+	defer timer.Stop()
+
+	pollChan := timer.C
+	if w.Timeout <= 0 {
+		timer.Stop()
+		pollChan = nil
+	}
+
+	var scheduleChan <-chan time.Time
+	var scheduleTimer *time.Timer
+	if w.schedule != nil {
+		scheduleTimer = time.NewTimer(time.Until(w.schedule.Next(time.Now())))
+		defer scheduleTimer.Stop()
+		scheduleChan = scheduleTimer.C
+	}
+
+	// the first check runs right away, instead of waiting for a full interval
+	for {
+
+		// in set mode, the whole set of addresses is compared instead
+		var result checkResult
+		if w.Set != nil {
+			result = w.checkSet()
+		} else {
+			result = w.checkSingle()
 		}
 
-		if w.executor != nil {
-			w.executor.ExecuteSlice(handler.Actions)
+		w.recordOutcome(result)
+		w.poller.Record(result)
+		w.saveState(result)
+		w.predict(result)
+		if pollChan != nil {
+			resetTimer(timer, w.poller.Next())
+		}
+
+		select {
+
+		case <-pollChan:
+		case at := <-scheduleChan:
+			scheduleTimer.Reset(time.Until(w.schedule.Next(at)))
+		case <-w.triggerChan:
+			w.poller.Hurry() // network changed, keep a close eye on the address for a while
+
+		case <-ctx.Done():
+			return
 		}
 	}
 }
 
-func (w *Watcher) errors() {
+// networkChanges triggers a check whenever the network changes (e.g. a PPPoE reconnect
+// or a DHCP renewal), waiting for the burst of notifications to settle first.
+func (w *Watcher) networkChanges(ctx context.Context) {
 
-	ctx := context.Background()
-	ctx = context.WithValue(ctx, "timestamp", time.Now())
+	var settle *time.Timer
+	err := watchNetwork(ctx.Done(), func() {
+		if settle == nil {
+			settle = time.AfterFunc(networkSettleTime, w.Trigger)
+			return
+		}
+		settle.Reset(networkSettleTime)
+	})
 
-	for err := range w.errorChan {
+	if err != nil {
+		w.logger.Warn().Err(err).Msg("not listening to network change notifications")
+	}
+}
 
-		if !errors.Is(err, ErrorNotifier) {
-			ctx = context.WithValue(ctx, "error", err)
-			w.HandleEvent("on_error", ctx) // handle on_error
+// recordOutcome keeps track of the consecutive failed checks, raising on_recover
+// with the outage details when a check succeeds after failing.
+func (w *Watcher) recordOutcome(result checkResult) {
+
+	if result == checkFailed {
+		if w.failures == 0 {
+			w.failingSince = time.Now()
 		}
+		w.failures++
+		return
+	}
 
-		w.logger.Error().Err(err).Msg("unexpected error")
+	if w.failures == 0 {
+		return
 	}
-}
 
-func (w *Watcher) check() {
+	event := NewEvent(EventRecover, w.Version)
+	event.Outage = &OutageDetails{
+		Since:    w.failingSince,
+		Duration: event.Timestamp.Sub(w.failingSince) - w.outageDowntime,
+		Failures: w.failures,
+	}
 
-	go w.errors()
+	w.logger.Info().
+		Dur("outage", event.Outage.Duration).
+		Int("failures", w.failures).
+		Msg("checks recovered")
 
-	var records = new(database.AddressEntry)
-	for {
+	w.publish(event) // handle on_recover
+	w.failures, w.outageDowntime = 0, 0
+}
+
+// resetTimer stops the timer, draining its channel if it fired meanwhile, and resets it to d
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
 		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(d)
+}
 
-		case <-w.ticker.C:
+// Trigger requests an immediate check, used when a network change is signaled.
+// Does not block if a check is already pending.
+func (w *Watcher) Trigger() {
+	select {
+	case w.triggerChan <- struct{}{}:
+	default:
+	}
+}
 
-			// get the address from the desired source
-			address, source, err := w.fetcher.RequestAddress(w.Version)
-			if err != nil {
-				w.errorChan <- errors.Join(err, ErrorFetch)
-				continue
+// checkSingle fetches the current address and compares it against the latest record
+func (w *Watcher) checkSingle() checkResult {
+
+	// get the address from the desired source
+	started := time.Now()
+	address, source, err := w.fetcher.RequestAddress(w.Version)
+	w.observe(address, source, err, time.Since(started))
+	if err != nil {
+		w.fail(errors.Join(err, ErrorFetch))
+		return checkFailed
+	}
+
+	// compare the WAN side address with the observed one, if configured
+	natStatus := w.detectNat(address, source)
+
+	// when tracking prefixes, the prefix is what gets compared
+	prefix, err := w.prefixOf(address)
+	if err != nil {
+		w.fail(errors.Join(err, ErrorFetch))
+		return checkFailed
+	}
+
+	// get latest address record of the database
+	previousAddress, err := latestBaseline(w.store, w.Version)
+	if err != nil {
+		w.fail(errors.Join(err, ErrorDatabase))
+		return checkFailed
+	}
+
+	// if the database is empty, or the baseline was reset, then we insert the current address
+	if previousAddress == nil {
+		_, err = w.store.CreateEntry(database.AddressEntry{
+			Address:         address,
+			PreviousAddress: address,
+			Version:         w.Version,
+			Prefix:          prefix,
+			Nat:             natStatus,
+		})
+		if err != nil {
+			w.fail(errors.Join(err, ErrorDatabase))
+			return checkFailed
+		}
+
+		return w.firstSeen(address, source)
+	}
+
+	// debounce the change and check for flapping before committing it
+	changed := w.changed(address, prefix, previousAddress)
+	observed := address
+	if prefix != "" {
+		observed = prefix
+	}
+
+	if !w.stabilize(observed, changed, source) && changed {
+		return checkPending
+	}
+
+	// compare addresses and handle accordingly
+	if changed {
+
+		w.logger.Info().
+			Str("previous_address", previousAddress.Address).
+			Str("current_address", address).
+			Str("current_prefix", prefix).
+			Msgf("detected address change")
+
+		_, err = w.store.CreateEntry(database.AddressEntry{ // insert new record onto the database
+			Address:         address,
+			PreviousAddress: previousAddress.Address,
+			Version:         w.Version,
+			Prefix:          prefix,
+			Nat:             natStatus,
+		})
+		if err != nil {
+			w.fail(errors.Join(err, ErrorDatabase))
+			return checkFailed
+		}
+
+		event := NewEvent(EventChange, w.Version)
+		event.Previous = previousAddress.Address
+		event.Current = address
+		event.Source = source
+		event.AfterDowntime = w.afterDowntime
+
+		if prefix != "" {
+			event.Prefix = &PrefixChange{
+				Previous: w.recordPrefix(previousAddress),
+				Current:  prefix,
 			}
+		}
 
-			// get latest address record of the database
-			previousAddress, err := records.First(w.Version)
+		// derive the addresses of the LAN hosts from the new prefix
+		if len(w.Hosts) > 0 {
+			hosts, err := deriveHosts(prefix, w.Hosts)
 			if err != nil {
-				w.errorChan <- errors.Join(err, ErrorDatabase)
-				continue
+				w.fail(errors.Join(err, ErrorFetch))
+			} else {
+				event.Hosts = hosts
 			}
+		}
 
-			// if the database is empty, then we insert the current address
-			if previousAddress == nil {
-				_, err = records.Create(address, w.Version, address)
-				if err != nil {
-					w.errorChan <- errors.Join(err, ErrorDatabase)
-				}
-				continue
-			}
+		w.publish(event) // handle on_change
+		return checkChanged
+	}
 
-			ctx := context.Background()
-			ctx = context.WithValue(ctx, "timestamp", time.Now())
+	w.logger.Info().Msgf("no address changes")
 
-			// compare addresses and handle accordingly
-			if address != previousAddress.Address {
+	event := NewEvent(EventMatch, w.Version)
+	event.Current = address
+	event.Source = source
 
-				w.logger.Info().
-					Str("previous_address", previousAddress.Address).
-					Str("current_address", address).
-					Msgf("detected address change")
+	w.publish(event) // handle on_match
+	return checkMatched
+}
 
-				_, err = records.Create(address, w.Version, previousAddress.Address) // insert new record onto the database
-				if err != nil {
-					w.errorChan <- errors.Join(err, ErrorDatabase)
-					continue
-				}
+// checkSet fetches the current set of addresses and compares it against the latest
+// stored snapshot, reporting which addresses were added and removed on on_change.
+func (w *Watcher) checkSet() checkResult {
 
-				ctx = context.WithValue(ctx, "previous_address", previousAddress.Address)
-				ctx = context.WithValue(ctx, "current_address", address)
-				ctx = context.WithValue(ctx, "source", source)
+	started := time.Now()
+	addresses, source, err := w.fetcher.RequestAddresses(w.Version, w.Set.Interfaces)
+	w.observe(database.JoinSet(addresses), source, err, time.Since(started))
+	if err != nil {
+		w.fail(errors.Join(err, ErrorFetch))
+		return checkFailed
+	}
 
-				go w.HandleEvent("on_change", ctx) // handle on_change
+	// get latest snapshot of the database
+	previousEntry, err := latestBaseline(w.store, w.Version)
+	if err != nil {
+		w.fail(errors.Join(err, ErrorDatabase))
+		return checkFailed
+	}
 
-			} else {
+	// if the database is empty, or the baseline was reset, then we insert the current snapshot,
+	// with no differences as there is no previous snapshot to compare it with
+	if previousEntry == nil {
+		_, err = w.store.CreateEntry(database.AddressEntry{
+			Address:         addresses[0],
+			PreviousAddress: addresses[0],
+			Addresses:       database.JoinSet(addresses),
+			Version:         w.Version,
+		})
+		if err != nil {
+			w.fail(errors.Join(err, ErrorDatabase))
+			return checkFailed
+		}
 
-				w.logger.Info().Msgf("no address changes")
+		return w.firstSeen(database.JoinSet(addresses), source)
+	}
 
-				ctx = context.WithValue(ctx, "source", source)
-				go w.HandleEvent("on_match", ctx) // handle on_match
-			}
+	previous := previousSet(previousEntry)
+	added, removed := diffSets(previous, addresses)
+	changed := len(added) > 0 || len(removed) > 0
 
-		case <-w.tickerQuitChan:
-			w.ticker.Stop()
+	// debounce the change and check for flapping before committing it
+	if !w.stabilize(database.JoinSet(addresses), changed, source) && changed {
+		return checkPending
+	}
+
+	if !changed {
+		w.logger.Info().Msgf("no address changes")
+
+		event := NewEvent(EventMatch, w.Version)
+		event.Current = database.JoinSet(addresses)
+		event.Source = source
 
+		w.publish(event) // handle on_match
+		return checkMatched
+	}
+
+	w.logger.Info().
+		Strs("added", added).
+		Strs("removed", removed).
+		Msgf("detected address set change")
+
+	_, err = w.store.CreateEntry(database.AddressEntry{ // insert new snapshot onto the database
+		Address:         addresses[0],
+		PreviousAddress: previousEntry.Address,
+		Addresses:       database.JoinSet(addresses),
+		Added:           database.JoinSet(added),
+		Removed:         database.JoinSet(removed),
+		Version:         w.Version,
+	})
+	if err != nil {
+		w.fail(errors.Join(err, ErrorDatabase))
+		return checkFailed
+	}
+
+	event := NewEvent(EventChange, w.Version)
+	event.Previous = database.JoinSet(previous)
+	event.Current = database.JoinSet(addresses)
+	event.Source = source
+	event.AfterDowntime = w.afterDowntime
+	event.Set = &SetChange{
+		Added:   added,
+		Removed: removed,
+	}
+
+	w.publish(event) // handle on_change
+	return checkChanged
+}
+
+// predict updates the prediction of the next change after a check with the given result,
+// tightening the polling around the predicted window
+func (w *Watcher) predict(result checkResult) {
+
+	if w.predictor == nil {
+		return
+	}
+
+	if err := w.predictor.Update(w.store, w.Version, result == checkChanged); err != nil {
+		w.fail(errors.Join(err, ErrorDatabase))
+		return
+	}
+
+	w.poller.Window(w.predictor.Window())
+}
+
+// observe records the check on the observations, if enabled
+func (w *Watcher) observe(address string, source string, err error, latency time.Duration) {
+
+	if w.observer == nil {
+		return
+	}
+
+	if err := w.observer.Observe(w.Version, address, source, err, latency); err != nil {
+		w.fail(errors.Join(err, ErrorDatabase))
+	}
+}
+
+// firstSeen raises on_first_seen for the first recorded address, and on_change as
+// well if 'watcher.first_run_as_change' is set.
+func (w *Watcher) firstSeen(address string, source string) checkResult {
+
+	w.logger.Info().Str("current_address", address).Msg("recorded first address")
+
+	event := NewEvent(EventFirstSeen, w.Version)
+	event.Current = address
+	event.Source = source
+
+	w.publish(event) // handle on_first_seen
+
+	if !w.FirstRunAsChange {
+		return checkMatched
+	}
+
+	change := NewEvent(EventChange, w.Version)
+	change.Current = address
+	change.Source = source
+
+	w.publish(change) // handle on_change
+	return checkChanged
+}
+
+// stabilize observes the value (address, prefix or address set) with the stabilizer,
+// raising on_flapping when it starts flapping. Returns whether a change can be committed,
+// changes pending confirmation or happening while flapping are held back.
+func (w *Watcher) stabilize(observed string, changed bool, source string) bool {
+
+	observation := w.stabilizer.Observe(observed, changed, time.Now())
+
+	if observation.FlapStarted {
+
+		w.logger.Warn().
+			Int("changes", observation.Changes).
+			Msg("address is flapping, suppressing changes until it stabilizes")
+
+		event := NewEvent(EventFlapping, w.Version)
+		event.Current = observed
+		event.Flapping = &FlapDetails{Changes: observation.Changes}
+		event.Source = source
+
+		w.publish(event) // handle on_flapping
+	}
+
+	if observation.FlapEnded {
+		w.logger.Info().Msg("address stabilized, no longer flapping")
+	}
+
+	if changed && !observation.Commit {
+		w.logger.Info().
+			Str("observed", observed).
+			Bool("flapping", w.stabilizer.Flapping()).
+			Msg("address change pending confirmation")
+	}
+
+	return observation.Commit
+}
+
+// detectNat compares the WAN side address with the observed address, raising on_nat
+// whenever the WAN side transitions to being behind a NAT. Returns the detected
+// status, or an empty string if detection is disabled or failed.
+func (w *Watcher) detectNat(address string, source string) string {
+
+	if w.nat == nil {
+		return ""
+	}
+
+	status, wan, err := w.nat.Detect(address, w.Version)
+	if err != nil {
+		w.fail(errors.Join(err, ErrorNat))
+		return ""
+	}
+
+	if status != w.natStatus && status != NatNone {
+
+		w.logger.Warn().
+			Str("wan_address", wan).
+			Str("current_address", address).
+			Str("nat", status).
+			Msg("detected NAT on the WAN side")
+
+		event := NewEvent(EventNat, w.Version)
+		event.Current = address
+		event.Nat = &NatDetails{
+			Status:     status,
+			WanAddress: wan,
 		}
+		event.Source = source
+
+		w.publish(event) // handle on_nat
 	}
+
+	w.natStatus = status
+	return status
 }
@@ -0,0 +1,438 @@
+package watcher
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gweebg/ipwatcher/internal/config"
+	"github.com/gweebg/ipwatcher/internal/database"
+	"github.com/rs/zerolog"
+)
+
+func TestMain(m *testing.M) {
+	logLevel = zerolog.Disabled // keep the test output readable
+	os.Exit(m.Run())
+}
+
+// testConfig is the configuration the watchers of the tests are created with, its single
+// source being served at the url formatted in, followed by the watcher settings of the test
+// and the path of its database
+const testConfig = `
+sources:
+  - name: test
+    url:
+      v4: "%v"
+    type: text
+
+watcher:
+  timeout: 60
+  observations:
+    enabled: true
+%v
+
+database:
+  path: "%v"
+`
+
+// fakeSource is a source returning the address set on it, as text
+type fakeSource struct {
+	mu      sync.Mutex
+	address string
+	server  *httptest.Server
+}
+
+func newFakeSource(t *testing.T, address string) *fakeSource {
+
+	source := &fakeSource{address: address}
+	source.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		source.mu.Lock()
+		defer source.mu.Unlock()
+
+		if source.address == "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, source.address)
+	}))
+	t.Cleanup(source.server.Close)
+
+	return source
+}
+
+// set changes the address returned by the source, an empty address fails the requests
+func (s *fakeSource) set(address string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.address = address
+}
+
+// recorder collects the events published on the bus
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) record(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// types returns the types of the recorded events, in order of publication
+func (r *recorder) types() []EventType {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	types := make([]EventType, 0, len(r.events))
+	for _, event := range r.events {
+		types = append(types, event.Type)
+	}
+
+	return types
+}
+
+// last returns the latest recorded event of the type, failing the test if there is none
+func (r *recorder) last(t *testing.T, eventType EventType) Event {
+	t.Helper()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := len(r.events) - 1; i >= 0; i-- {
+		if r.events[i].Type == eventType {
+			return r.events[i]
+		}
+	}
+
+	t.Fatalf("expected an %v event, got %v", eventType, r.events)
+	return Event{}
+}
+
+// loadConfig initializes the configuration with testConfig, adding settings to the watcher
+func loadConfig(t *testing.T, source *fakeSource, settings string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	content := fmt.Sprintf(testConfig, source.server.URL, settings, filepath.Join(dir, "watcher.db"))
+	if err := os.WriteFile(filepath.Join(dir, "config.yml"), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the configuration is read from the working directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	version, disabled := "v4", false
+	config.Init(map[string]interface{}{
+		"version": &version,
+		"exec":    &disabled,
+		"api":     &disabled,
+		"notify":  &disabled,
+	})
+}
+
+// newTestWatcher creates a watcher on a MemoryStore, checking the address of source,
+// with the events published recorded
+func newTestWatcher(t *testing.T, source *fakeSource, settings string) (*Watcher, *database.MemoryStore, *recorder) {
+	t.Helper()
+
+	loadConfig(t, source, settings)
+
+	store := database.NewMemoryStore()
+	w := NewWatcher(store)
+
+	events := &recorder{}
+	w.bus.Subscribe("test", events.record)
+	t.Cleanup(w.bus.Close)
+
+	return w, store, events
+}
+
+// flush waits for the published events to be handled
+func flush(w *Watcher) {
+	w.bus.Close()
+}
+
+// entries returns the records of the store for v4, failing the test on an error
+func entries(t *testing.T, store database.Store) []database.AddressEntry {
+	t.Helper()
+
+	entries, err := store.Entries("v4", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return entries
+}
+
+func expectResult(t *testing.T, got checkResult, expected checkResult) {
+	t.Helper()
+	if got != expected {
+		t.Fatalf("expected the check result %v, got %v", expected, got)
+	}
+}
+
+func TestCheckFirstSeen(t *testing.T) {
+
+	source := newFakeSource(t, "203.0.113.1")
+	w, store, events := newTestWatcher(t, source, "")
+
+	expectResult(t, w.checkSingle(), checkMatched)
+	flush(w)
+
+	recorded := entries(t, store)
+	if len(recorded) != 1 || recorded[0].Address != "203.0.113.1" || recorded[0].IsChange() {
+		t.Fatalf("expected the first address to be recorded as its own previous address, got %+v", recorded)
+	}
+
+	if types := events.types(); len(types) != 1 || types[0] != EventFirstSeen {
+		t.Fatalf("expected a single on_first_seen event, got %v", types)
+	}
+	if event := events.last(t, EventFirstSeen); event.Current != "203.0.113.1" || event.Source != source.server.URL {
+		t.Fatalf("unexpected on_first_seen event %+v", event)
+	}
+
+	observations, err := store.Observations("v4", time.Time{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(observations) != 1 || observations[0].Address != "203.0.113.1" {
+		t.Fatalf("expected the check to be observed, got %+v", observations)
+	}
+}
+
+func TestCheckFirstRunAsChange(t *testing.T) {
+
+	source := newFakeSource(t, "203.0.113.1")
+	w, _, events := newTestWatcher(t, source, "  first_run_as_change: true")
+
+	expectResult(t, w.checkSingle(), checkChanged)
+	flush(w)
+
+	if types := events.types(); len(types) != 2 || types[0] != EventFirstSeen || types[1] != EventChange {
+		t.Fatalf("expected on_first_seen followed by on_change, got %v", types)
+	}
+	if event := events.last(t, EventChange); event.Current != "203.0.113.1" || event.Previous != "" {
+		t.Fatalf("unexpected on_change event %+v", event)
+	}
+}
+
+func TestCheckMatch(t *testing.T) {
+
+	source := newFakeSource(t, "203.0.113.1")
+	w, store, events := newTestWatcher(t, source, "")
+
+	expectResult(t, w.checkSingle(), checkMatched)
+	expectResult(t, w.checkSingle(), checkMatched)
+	flush(w)
+
+	if recorded := entries(t, store); len(recorded) != 1 {
+		t.Fatalf("expected a match not to be recorded, got %+v", recorded)
+	}
+
+	if event := events.last(t, EventMatch); event.Current != "203.0.113.1" {
+		t.Fatalf("unexpected on_match event %+v", event)
+	}
+}
+
+func TestCheckChange(t *testing.T) {
+
+	source := newFakeSource(t, "203.0.113.1")
+	w, store, events := newTestWatcher(t, source, "")
+
+	expectResult(t, w.checkSingle(), checkMatched)
+	source.set("203.0.113.2")
+	expectResult(t, w.checkSingle(), checkChanged)
+	flush(w)
+
+	recorded := entries(t, store)
+	if len(recorded) != 2 {
+		t.Fatalf("expected the change to be recorded, got %+v", recorded)
+	}
+	if change := recorded[1]; change.Address != "203.0.113.2" || change.PreviousAddress != "203.0.113.1" || !change.IsChange() {
+		t.Fatalf("unexpected change record %+v", change)
+	}
+
+	event := events.last(t, EventChange)
+	if event.Current != "203.0.113.2" || event.Previous != "203.0.113.1" || event.AfterDowntime {
+		t.Fatalf("unexpected on_change event %+v", event)
+	}
+}
+
+func TestCheckChangeConfirmed(t *testing.T) {
+
+	source := newFakeSource(t, "203.0.113.1")
+	w, store, _ := newTestWatcher(t, source, "  confirm:\n    checks: 2")
+
+	expectResult(t, w.checkSingle(), checkMatched)
+	source.set("203.0.113.2")
+	expectResult(t, w.checkSingle(), checkPending)
+
+	if recorded := entries(t, store); len(recorded) != 1 {
+		t.Fatalf("expected the pending change not to be recorded, got %+v", recorded)
+	}
+
+	expectResult(t, w.checkSingle(), checkChanged)
+	if recorded := entries(t, store); len(recorded) != 2 {
+		t.Fatalf("expected the confirmed change to be recorded, got %+v", recorded)
+	}
+}
+
+func TestCheckAfterReset(t *testing.T) {
+
+	source := newFakeSource(t, "203.0.113.1")
+	w, store, events := newTestWatcher(t, source, "")
+
+	expectResult(t, w.checkSingle(), checkMatched)
+	if _, err := ResetBaseline(store, "v4"); err != nil {
+		t.Fatal(err)
+	}
+
+	source.set("203.0.113.2")
+	expectResult(t, w.checkSingle(), checkMatched)
+	flush(w)
+
+	if types := events.types(); len(types) != 2 || types[1] != EventFirstSeen {
+		t.Fatalf("expected the check after a reset to be a first run, got %v", types)
+	}
+
+	// the history before the reset is kept
+	recorded := entries(t, store)
+	if len(recorded) != 3 || !recorded[1].IsReset() || recorded[2].Address != "203.0.113.2" || recorded[2].IsChange() {
+		t.Fatalf("expected the reset marker followed by a first run, got %+v", recorded)
+	}
+}
+
+func TestCheckSet(t *testing.T) {
+
+	// loopback addresses are not global, so the set is made of the source address alone
+	source := newFakeSource(t, "203.0.113.1")
+	w, store, events := newTestWatcher(t, source, "  set:\n    interfaces: [lo]")
+
+	expectResult(t, w.checkSet(), checkMatched)
+
+	recorded := entries(t, store)
+	if len(recorded) != 1 || recorded[0].Addresses != "203.0.113.1" || recorded[0].Added != "" || recorded[0].IsChange() {
+		t.Fatalf("expected the first snapshot to be recorded without differences, got %+v", recorded)
+	}
+
+	expectResult(t, w.checkSet(), checkMatched)
+
+	source.set("203.0.113.2")
+	expectResult(t, w.checkSet(), checkChanged)
+	flush(w)
+
+	recorded = entries(t, store)
+	if len(recorded) != 2 {
+		t.Fatalf("expected the set change to be recorded, got %+v", recorded)
+	}
+	if change := recorded[1]; change.Addresses != "203.0.113.2" || change.Added != "203.0.113.2" || change.Removed != "203.0.113.1" {
+		t.Fatalf("unexpected set change record %+v", change)
+	}
+
+	event := events.last(t, EventChange)
+	if event.Previous != "203.0.113.1" || event.Current != "203.0.113.2" || event.Set == nil ||
+		len(event.Set.Added) != 1 || event.Set.Added[0] != "203.0.113.2" ||
+		len(event.Set.Removed) != 1 || event.Set.Removed[0] != "203.0.113.1" {
+		t.Fatalf("unexpected on_change event %+v", event)
+	}
+}
+
+func TestCheckFailed(t *testing.T) {
+
+	source := newFakeSource(t, "")
+	w, store, events := newTestWatcher(t, source, "")
+
+	expectResult(t, w.checkSingle(), checkFailed)
+	flush(w)
+
+	if recorded := entries(t, store); len(recorded) != 0 {
+		t.Fatalf("expected nothing to be recorded, got %+v", recorded)
+	}
+
+	if event := events.last(t, EventError); event.Err == nil {
+		t.Fatalf("expected the on_error event to carry the error, got %+v", event)
+	}
+}
+
+func TestRecover(t *testing.T) {
+
+	source := newFakeSource(t, "")
+	w, _, events := newTestWatcher(t, source, "")
+
+	for i := 0; i < 2; i++ {
+		w.recordOutcome(w.checkSingle())
+	}
+
+	source.set("203.0.113.1")
+	w.recordOutcome(w.checkSingle())
+	flush(w)
+
+	if event := events.last(t, EventRecover); event.Outage == nil || event.Outage.Failures != 2 {
+		t.Fatalf("expected on_recover after 2 failures, got %+v", event)
+	}
+}
+
+// TestRestoreState checks that a run restarted while failing carries on with the outage,
+// and flags the change that happened while it was down
+func TestRestoreState(t *testing.T) {
+
+	source := newFakeSource(t, "203.0.113.2")
+	w, store, events := newTestWatcher(t, source, "")
+
+	if _, err := SeedBaseline(store, "v4", "203.0.113.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	lastCheck := time.Now().Add(-time.Hour)
+	err := store.SaveState(database.RuntimeState{
+		Version:           "v4",
+		ConsecutiveErrors: 3,
+		FailingSince:      lastCheck.Add(-time.Hour),
+		LastCheck:         lastCheck,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.restoreState()
+	if w.poller.failures != 3 {
+		t.Fatalf("expected the checks to keep backing off, got %d failures", w.poller.failures)
+	}
+
+	result := w.checkSingle()
+	expectResult(t, result, checkChanged)
+	w.recordOutcome(result)
+	flush(w)
+
+	downtime := events.last(t, EventDowntime).Downtime
+	if downtime == nil || !downtime.Since.Equal(lastCheck) || downtime.Graceful {
+		t.Fatalf("unexpected downtime %+v", downtime)
+	}
+
+	if event := events.last(t, EventChange); !event.AfterDowntime {
+		t.Fatalf("expected the change to be flagged as after downtime, got %+v", event)
+	}
+
+	// the outage started two hours ago, one of which the watcher was down for
+	outage := events.last(t, EventRecover).Outage
+	if outage == nil || outage.Failures != 3 || outage.Duration < time.Hour-time.Minute || outage.Duration > time.Hour+time.Minute {
+		t.Fatalf("expected the outage to last an hour, got %+v", outage)
+	}
+}